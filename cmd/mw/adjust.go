@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// A change to a number given on the command line. Values starting with + or -
+// are relative to the current one, and values ending in % are hundredths.
+//
+//   0.5    set to 0.5
+//   +0.1   raise by 0.1
+//   -10%   lower by 0.1
+type adjustment struct {
+	value    float64
+	relative bool
+}
+
+// Read an adjustment like 0.5, +0.1 or -10%.
+func parseAdjustment(arg string) (adjustment, error) {
+	var a adjustment
+	a.relative = strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")
+
+	number := strings.TrimSuffix(arg, "%")
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return a, fmt.Errorf("Invalid value %q", arg)
+	}
+	if number != arg {
+		value /= 100
+	}
+	a.value = value
+	return a, nil
+}
+
+// Get the new value after adjusting current, kept between min and max.
+func (a adjustment) apply(current, min, max float64) float64 {
+	value := a.value
+	if a.relative {
+		value += current
+	}
+	return math.Max(min, math.Min(max, value))
+}
+
+// Get the new rate after adjusting the player's, kept within the limits it
+// reports. Limits of zero weren't reported and are ignored. Rates of zero or
+// less are refused, since players treat a rate of zero as pausing.
+func adjustRate(change adjustment, state mpris.Snapshot) (float64, error) {
+	min, max := 0.0, math.Inf(1)
+	if state.MinimumRate > 0 {
+		min = state.MinimumRate
+	}
+	if state.MaximumRate > 0 {
+		max = state.MaximumRate
+	}
+	rate := change.apply(state.Rate, min, max)
+	if rate <= 0 {
+		return 0, fmt.Errorf("Rate should be above 0, not %g", rate)
+	}
+	return rate, nil
+}
+
+// Read a track position like 1:23, 1:02:03, 83, 1m23s or 2.5. Values starting
+// with + or - are relative to the current position.
+func parsePosition(arg string) (offset time.Duration, relative bool, err error) {
+	value := arg
+	sign := time.Duration(1)
+	if len(arg) > 0 && (arg[0] == '+' || arg[0] == '-') {
+		relative = true
+		if arg[0] == '-' {
+			sign = -1
+		}
+		value = arg[1:]
+	}
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		return 0, false, fmt.Errorf("Invalid position %q", arg)
+	}
+
+	switch {
+	case strings.Contains(value, ":"):
+		for _, part := range strings.Split(value, ":") {
+			number, err := strconv.ParseFloat(part, 64)
+			if err != nil || number < 0 {
+				return 0, false, fmt.Errorf("Invalid position %q", arg)
+			}
+			offset = offset*60 + time.Duration(number*float64(time.Second))
+		}
+	case strings.IndexAny(value, "hms") >= 0:
+		offset, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, false, fmt.Errorf("Invalid position %q", arg)
+		}
+	default:
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("Invalid position %q", arg)
+		}
+		offset = time.Duration(seconds * float64(time.Second))
+	}
+	return sign * offset, relative, nil
+}
+
+// Read shuffle as on, off or toggle.
+func parseShuffle(arg string, current bool) (bool, error) {
+	switch strings.ToLower(arg) {
+	case "on", "true", "yes":
+		return true, nil
+	case "off", "false", "no":
+		return false, nil
+	case "toggle":
+		return !current, nil
+	}
+	return false, fmt.Errorf("Shuffle should be on, off or toggle, not %q", arg)
+}
+
+// Read a loop status as none, track or playlist, ignoring case.
+func parseLoop(arg string) (mpris.LoopState, error) {
+	state := mpris.LoopState(strings.Title(strings.ToLower(arg)))
+	if !state.Valid() {
+		return "", fmt.Errorf("Loop should be none, track, playlist or cycle, not %q", arg)
+	}
+	return state, nil
+}
+
+// Fail unless the player accepts changes to its properties.
+func requireControl(p *mpris.Player) error {
+	if !p.CanControl() {
+		return fmt.Errorf("%s can't be controlled", p.ShortName())
+	}
+	return nil
+}
+
+// Fail unless the player can move around in the current track.
+func requireSeek(p *mpris.Player) error {
+	if !p.CanSeek() {
+		return fmt.Errorf("%s can't seek", p.ShortName())
+	}
+	return nil
+}
+
+// Move to a position in the current track, kept between the start and end of
+// the track. Relative moves add the offset to the current position.
+func seekTo(p *mpris.Player, offset time.Duration, relative bool) error {
+	if err := requireSeek(p); err != nil {
+		return err
+	}
+	meta, err := p.GetMetadata()
+	if err != nil {
+		return err
+	}
+	current, err := p.GetPosition()
+	if err != nil {
+		return err
+	}
+
+	target := seekTarget(offset, relative, current, meta.Length)
+
+	// SetPosition needs to know the track, so players without track ids can
+	// only seek.
+	if meta.TrackID == "" {
+		return p.Seek(target - current)
+	}
+	return p.SetPosition(meta.TrackID, target)
+}
+
+// Get the position in microseconds to move to from current, kept between the
+// start of the track and its length, if known.
+func seekTarget(offset time.Duration, relative bool, current, length int64) int64 {
+	target := offset.Microseconds()
+	if relative {
+		target += current
+	}
+	if target < 0 {
+		target = 0
+	}
+	if length > 0 && target > length {
+		target = length
+	}
+	return target
+}
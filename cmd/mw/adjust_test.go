@@ -0,0 +1,168 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+func TestParseAdjustment(t *testing.T) {
+	tests := []struct {
+		arg      string
+		value    float64
+		relative bool
+		err      bool
+	}{
+		{"0.5", 0.5, false, false},
+		{"1", 1, false, false},
+		{"+0.1", 0.1, true, false},
+		{"-0.1", -0.1, true, false},
+		{"50%", 0.5, false, false},
+		{"+10%", 0.1, true, false},
+		{"-10%", -0.1, true, false},
+		{"", 0, false, true},
+		{"%", 0, false, true},
+		{"loud", 0, false, true},
+		{"+", 0, true, true},
+	}
+	for _, test := range tests {
+		got, err := parseAdjustment(test.arg)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseAdjustment(%q) = %+v, want an error", test.arg, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAdjustment(%q) failed: %v", test.arg, err)
+			continue
+		}
+		if got.value != test.value || got.relative != test.relative {
+			t.Errorf("parseAdjustment(%q) = %+v, want {value:%g relative:%v}", test.arg, got, test.value, test.relative)
+		}
+	}
+}
+
+func TestAdjustmentApply(t *testing.T) {
+	tests := []struct {
+		change   adjustment
+		current  float64
+		min, max float64
+		want     float64
+	}{
+		{adjustment{0.5, false}, 0.2, 0, 1, 0.5},
+		{adjustment{0.25, true}, 0.5, 0, 1, 0.75},
+		{adjustment{-0.25, true}, 0.5, 0, 1, 0.25},
+		{adjustment{0.75, true}, 0.5, 0, 1, 1},
+		{adjustment{-0.75, true}, 0.5, 0, 1, 0},
+		{adjustment{2, false}, 0.5, 0, 1, 1},
+	}
+	for _, test := range tests {
+		got := test.change.apply(test.current, test.min, test.max)
+		if got != test.want {
+			t.Errorf("%+v.apply(%g, %g, %g) = %g, want %g", test.change, test.current, test.min, test.max, got, test.want)
+		}
+	}
+}
+
+func TestAdjustRate(t *testing.T) {
+	tests := []struct {
+		change   adjustment
+		rate     float64
+		min, max float64
+		want     float64
+		err      bool
+	}{
+		{adjustment{1.5, false}, 1, 0.5, 2, 1.5, false},
+		{adjustment{3, false}, 1, 0.5, 2, 2, false},
+		{adjustment{-1, true}, 1, 0.5, 2, 0.5, false},
+		{adjustment{3, false}, 1, 0, 0, 3, false},
+		{adjustment{0.25, true}, 1, 0, 0, 1.25, false},
+		{adjustment{-1, true}, 1, 0, 0, 0, true},
+		{adjustment{0, false}, 1, 0, 0, 0, true},
+		{adjustment{-0.5, false}, 1, 0, 2, 0, true},
+	}
+	for _, test := range tests {
+		state := mpris.Snapshot{Rate: test.rate, MinimumRate: test.min, MaximumRate: test.max}
+		got, err := adjustRate(test.change, state)
+		if test.err {
+			if err == nil {
+				t.Errorf("adjustRate(%+v) from %g in [%g, %g] = %g, want an error", test.change, test.rate, test.min, test.max, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("adjustRate(%+v) from %g in [%g, %g] = %g, %v, want %g", test.change, test.rate, test.min, test.max, got, err, test.want)
+		}
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		arg      string
+		offset   time.Duration
+		relative bool
+		err      bool
+	}{
+		{"83", 83 * time.Second, false, false},
+		{"2.5", 2500 * time.Millisecond, false, false},
+		{"1:23", 83 * time.Second, false, false},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false, false},
+		{"1m23s", 83 * time.Second, false, false},
+		{"+10", 10 * time.Second, true, false},
+		{"-10", -10 * time.Second, true, false},
+		{"-1:30", -90 * time.Second, true, false},
+		{"+1m", time.Minute, true, false},
+		{"", 0, false, true},
+		{"1:xx", 0, false, true},
+		{"1:-5", 0, false, true},
+		{"5q", 0, false, true},
+		{"soon", 0, false, true},
+		{"+-5", 0, false, true},
+		{"--5", 0, false, true},
+		{"++5", 0, false, true},
+		{"-+1m", 0, false, true},
+	}
+	for _, test := range tests {
+		offset, relative, err := parsePosition(test.arg)
+		if test.err {
+			if err == nil {
+				t.Errorf("parsePosition(%q) = %v, %v, want an error", test.arg, offset, relative)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePosition(%q) failed: %v", test.arg, err)
+			continue
+		}
+		if offset != test.offset || relative != test.relative {
+			t.Errorf("parsePosition(%q) = %v, %v, want %v, %v", test.arg, offset, relative, test.offset, test.relative)
+		}
+	}
+}
+
+func TestSeekTarget(t *testing.T) {
+	tests := []struct {
+		offset   time.Duration
+		relative bool
+		current  int64
+		length   int64
+		want     int64
+	}{
+		{30 * time.Second, false, 10e6, 200e6, 30e6},
+		{10 * time.Second, true, 10e6, 200e6, 20e6},
+		{-5 * time.Second, true, 10e6, 200e6, 5e6},
+		{-20 * time.Second, true, 10e6, 200e6, 0},
+		{-5 * time.Second, false, 10e6, 200e6, 0},
+		{300 * time.Second, false, 10e6, 200e6, 200e6},
+		{195 * time.Second, true, 10e6, 200e6, 200e6},
+		{300 * time.Second, false, 10e6, 0, 300e6},
+	}
+	for _, test := range tests {
+		got := seekTarget(test.offset, test.relative, test.current, test.length)
+		if got != test.want {
+			t.Errorf("seekTarget(%v, %v, %d, %d) = %d, want %d", test.offset, test.relative, test.current, test.length, got, test.want)
+		}
+	}
+}
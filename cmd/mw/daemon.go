@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/godbus/dbus/v5"
+	"github.com/shreve/musicwand/internal/pkg/musicwand"
 	"github.com/shreve/musicwand/pkg/mpris"
 )
 
@@ -15,19 +25,19 @@ import (
 // Controls and information about the media player application.
 //
 type appServer struct {
-	client *mpris.Player
+	state *State
 }
 
 func (a *appServer) Quit() *dbus.Error {
-	if a.client != nil {
-		a.client.Quit()
+	if client := a.state.currentPlayer(); client != nil {
+		client.Quit()
 	}
 	return nil
 }
 
 func (a *appServer) Raise() *dbus.Error {
-	if a.client != nil {
-		a.client.Raise()
+	if client := a.state.currentPlayer(); client != nil {
+		client.Raise()
 	}
 	return nil
 }
@@ -38,68 +48,68 @@ func (a *appServer) Raise() *dbus.Error {
 // Controls for the playback of media.
 //
 type playerServer struct {
-	client *mpris.Player
+	state *State
 }
 
 func (p playerServer) Next() *dbus.Error {
-	if p.client != nil {
-		p.client.Next()
+	if client := p.state.currentPlayer(); client != nil {
+		client.Next()
 	}
 	return nil
 }
 
 func (p playerServer) OpenUri(uri string) *dbus.Error {
-	if p.client != nil {
-		p.client.OpenUri(uri)
+	if client := p.state.currentPlayer(); client != nil {
+		client.OpenUri(uri)
 	}
 	return nil
 }
 
 func (p playerServer) Pause() *dbus.Error {
-	if p.client != nil {
-		p.client.Pause()
+	if client := p.state.currentPlayer(); client != nil {
+		client.Pause()
 	}
 	return nil
 }
 
 func (p playerServer) Play() *dbus.Error {
-	if p.client != nil {
-		p.client.Play()
+	if client := p.state.currentPlayer(); client != nil {
+		client.Play()
 	}
 	return nil
 }
 
 func (p playerServer) PlayPause() *dbus.Error {
-	if p.client != nil {
-		p.client.PlayPause()
+	if client := p.state.currentPlayer(); client != nil {
+		client.PlayPause()
 	}
 	return nil
 }
 
 func (p playerServer) Previous() *dbus.Error {
-	if p.client != nil {
-		p.client.Previous()
+	if client := p.state.currentPlayer(); client != nil {
+		client.Previous()
 	}
 	return nil
 }
 
 func (p playerServer) Seek(delta int64) *dbus.Error {
-	if p.client != nil {
-		p.client.Seek(delta)
+	if client := p.state.currentPlayer(); client != nil {
+		client.Seek(delta)
 	}
 	return nil
 }
 
 func (p playerServer) SetPosition(trackId string, position int64) *dbus.Error {
-	if p.client != nil {
-		p.client.SetPosition(trackId, position)
+	if client := p.state.currentPlayer(); client != nil {
+		client.SetPosition(trackId, position)
 	}
 	return nil
 }
 
 func (p playerServer) Stop() *dbus.Error {
-	if p.client != nil {
-		p.client.Stop()
+	if client := p.state.currentPlayer(); client != nil {
+		client.Stop()
 	}
 	return nil
 }
@@ -110,71 +120,389 @@ func (p playerServer) Stop() *dbus.Error {
 // Forwards properties from destination object.
 //
 type propertyHandler struct {
-	client *mpris.Player
+	state *State
 }
 
 func (p propertyHandler) Get(iface, prop string) (dbus.Variant, *dbus.Error) {
-	if p.client != nil {
-		result, err := p.client.Get(iface, prop)
-		return result, mpris.DbusError(err)
+	if client := p.state.currentPlayer(); client != nil {
+		result, err := client.Get(iface, prop)
+		return proxiedValue(iface, prop, result), mpris.DbusError(err)
 	}
 	return dbus.MakeVariant(""), nil
 }
 
 func (p propertyHandler) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
 	// Prevent recursion
-	if iface == "org.freedesktop.DBus.Properties" || iface == "com.github.shreve.musicwand" {
+	if iface == "org.freedesktop.DBus.Properties" || iface == daemonInterface {
 		return nil, nil
 	}
-	if p.client != nil {
-		result, err := p.client.GetAll(iface)
+	if client := p.state.currentPlayer(); client != nil {
+		result, err := client.GetAll(iface)
+		proxyValues(iface, result)
 		return result, mpris.DbusError(err)
 	}
 	return nil, nil
 }
 
 func (p propertyHandler) Set(iface, prop string, value dbus.Variant) *dbus.Error {
-	if p.client != nil {
-		err := p.client.Set(iface, prop, value)
+	if client := p.state.currentPlayer(); client != nil {
+		err := client.Set(iface, prop, value)
 		return mpris.DbusError(err)
 	}
 	return nil
 }
 
+// The interfaces whose properties are mirrored from the current player.
+var proxiedInterfaces = []string{
+	"org.mpris.MediaPlayer2",
+	"org.mpris.MediaPlayer2.Player",
+}
+
+// Get what the proxy reports for a property of the current player. The proxy
+// doesn't forward the TrackList interface, so it never claims to have one.
+func proxiedValue(iface, prop string, value dbus.Variant) dbus.Variant {
+	if iface == "org.mpris.MediaPlayer2" && prop == "HasTrackList" {
+		return dbus.MakeVariant(false)
+	}
+	return value
+}
+
+// Replace each property of the current player with what the proxy reports.
+func proxyValues(iface string, props map[string]dbus.Variant) {
+	for prop, value := range props {
+		props[prop] = proxiedValue(iface, prop, value)
+	}
+}
+
+//
+// State
+//
+// Which player the proxy forwards to, and what it last looked like.
+//
 type State struct {
-	client        mpris.Client
-	CurrentPlayer *mpris.Player
+	client *mpris.Client
+	server *mpris.Server
+	config musicwand.Config
+	policy musicwand.SelectionPolicy
+	list   []mpris.Player // Players which can be followed, in priority order
+
+	// Guards config, policy and list. Policies don't need to be safe for
+	// concurrent use. Players are never asked anything with this lock held.
+	selecting sync.Mutex
+
+	// Held while the proxy catches up with a player, so clients hear about
+	// changes in order. Bus calls are only made with this lock held, never mu.
+	syncing   sync.Mutex
+	announced *mpris.Player      // The player clients last heard about
+	stopSeeks context.CancelFunc // Stops passing on the announced player's seeks
+
+	mu      sync.Mutex
+	current *mpris.Player
+	props   map[string]map[string]dbus.Variant
 }
 
-func (s *State) SetCurrentPlayer(name string) *dbus.Error {
-	newPlayer := s.client.FindPlayer(name)
-	if newPlayer != nil {
-		s.CurrentPlayer = newPlayer
-	} else {
-		return dbus.NewError("Unable to find that app", []interface{}{})
+// Follow the player with the given name or alias, as far as the selection
+// policy allows. Returns the bus name of the player now being followed.
+func (s *State) SetCurrentPlayer(name string) (string, *dbus.Error) {
+	s.selecting.Lock()
+	name = s.config.Resolve(name)
+	s.selecting.Unlock()
+
+	// Leave ourselves out, since asking our own identity would deadlock.
+	found := mpris.FilterPlayers(s.players(), name)
+	if len(found) == 0 {
+		return "", dbus.NewError(errNotFound, []interface{}{"Unable to find that app"})
 	}
-	return nil
+	return s.choose(&found[0])
 }
 
-func (s *State) setPlayer(player *mpris.Player) {
-	log.Println("Setting current app to:", player.Name)
-	s.CurrentPlayer = player
+// Get the bus name of the player being followed, or an empty string if none.
+func (s *State) CurrentPlayer() (string, *dbus.Error) {
+	if current := s.currentPlayer(); current != nil {
+		return current.Name, nil
+	}
+	return "", nil
 }
 
-func (s *State) selectPlayer() {
-	players := s.client.Players()
+// Follow the next running player, or the previous one if reverse is true.
+// Returns the bus name of the player now being followed.
+func (s *State) CyclePlayer(reverse bool) (string, *dbus.Error) {
+	players := s.players()
 	if len(players) == 0 {
-		log.Println("Unable to connect to any music players")
+		return "", dbus.NewError(errNotFound, []interface{}{"No apps are running"})
+	}
+
+	current := s.currentPlayer()
+	next := 0
+	if reverse {
+		next = len(players) - 1
+	}
+	for i := range players {
+		if !samePlayer(current, &players[i]) {
+			continue
+		}
+		if reverse {
+			next = (i + len(players) - 1) % len(players)
+		} else {
+			next = (i + 1) % len(players)
+		}
+	}
+	return s.choose(&players[next])
+}
+
+// Ask the policy to follow a player the user picked.
+func (s *State) choose(player *mpris.Player) (string, *dbus.Error) {
+	s.selectPlayer(musicwand.SelectionEvent{Reason: musicwand.SelectManual, Player: player})
+	current := s.currentPlayer()
+	if !samePlayer(current, player) {
+		return "", dbus.NewError(errRefused, []interface{}{"The selection policy refused that app"})
+	}
+	return current.Name, nil
+}
+
+// Get the player requests are currently forwarded to, which may be nil.
+func (s *State) currentPlayer() *mpris.Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Check if a player is the musicwand proxy itself.
+func (s *State) isSelf(player *mpris.Player) bool {
+	return player.Name == s.server.BusName()
+}
+
+// Switch to a new player, or to none if player is nil. Returns false if it's
+// the player already being followed.
+func (s *State) setPlayer(player *mpris.Player) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if samePlayer(s.current, player) {
+		return false
+	}
+	s.current = player
+	return true
+}
+
+// Tell clients about everything that's different since the player they last
+// heard about, if the current player has changed since then.
+func (s *State) announce() {
+	s.syncing.Lock()
+	defer s.syncing.Unlock()
+
+	player := s.currentPlayer()
+	if samePlayer(s.announced, player) {
+		return
+	}
+	s.announced = player
+	if s.stopSeeks != nil {
+		s.stopSeeks()
+		s.stopSeeks = nil
+	}
+
+	s.sync(player, proxiedInterfaces...)
+	if player == nil {
+		log.Println("No current app")
 		return
 	}
+	log.Println("Setting current app to:", player.Name)
+	s.forwardSeeks(player)
+
+	// Position never appears in PropertiesChanged, so announce it as a jump.
+	if position, err := player.GetPosition(); err == nil {
+		s.server.Seeked(position)
+	}
+}
 
-	for _, player := range players {
-		if player.PlaybackStatus() == mpris.PlaybackPlaying {
-			s.setPlayer(&player)
+// Pass on each seek by a player to clients for as long as it's followed. Must
+// be called with the syncing lock held.
+func (s *State) forwardSeeks(player *mpris.Player) {
+	ctx, cancel := context.WithCancel(context.Background())
+	seeks, err := player.WithContext(ctx).OnSeek()
+	if err != nil {
+		cancel()
+		log.Println("Unable to watch for seeks:", err)
+		return
+	}
+	s.stopSeeks = cancel
+
+	go func() {
+		for event := range seeks {
+			if samePlayer(s.currentPlayer(), player) {
+				s.server.Seeked(event.Position)
+			}
+		}
+	}()
+}
+
+// Load the announced player's properties on an interface again and tell
+// clients what changed. Interfaces which aren't proxied are ignored.
+func (s *State) refresh(iface string) {
+	for _, proxied := range proxiedInterfaces {
+		if iface == proxied {
+			s.syncing.Lock()
+			defer s.syncing.Unlock()
+			s.sync(s.announced, iface)
 			return
 		}
 	}
-	s.setPlayer(&players[0])
+}
+
+// Emit PropertiesChanged for each property of a player on the given interfaces
+// which differs from what clients last saw. The player is asked without the
+// lock held, so a slow one doesn't hold up requests. Must be called with the
+// syncing lock held.
+func (s *State) sync(player *mpris.Player, ifaces ...string) {
+	for _, iface := range ifaces {
+		props := map[string]dbus.Variant{}
+		if player != nil {
+			all, err := player.GetAll(iface)
+			if err != nil {
+				continue
+			}
+			proxyValues(iface, all)
+			props = all
+		}
+
+		changed, invalidated := s.swapProps(iface, props)
+		if len(changed) > 0 || len(invalidated) > 0 {
+			s.server.EmitPropertiesChanged(iface, changed, invalidated)
+		}
+	}
+}
+
+// Remember the properties clients now see on an interface, and get which of
+// them changed or went away since last time.
+func (s *State) swapProps(iface string, props map[string]dbus.Variant) (changed map[string]dbus.Variant, invalidated []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.props == nil {
+		s.props = make(map[string]map[string]dbus.Variant)
+	}
+
+	changed = make(map[string]dbus.Variant)
+	for name, value := range props {
+		if name == "Position" {
+			continue
+		}
+		old, ok := s.props[iface][name]
+		if !ok || !reflect.DeepEqual(old.Value(), value.Value()) {
+			changed[name] = value
+		}
+	}
+	for name := range s.props[iface] {
+		if _, ok := props[name]; !ok {
+			invalidated = append(invalidated, name)
+		}
+	}
+	s.props[iface] = props
+	return
+}
+
+// Use a new config, switching policies if it names a different one.
+func (s *State) setConfig(config musicwand.Config) error {
+	s.selecting.Lock()
+	if s.policy == nil || config.Policy != s.config.Policy || !reflect.DeepEqual(config.Priority, s.config.Priority) {
+		policy, err := musicwand.NewSelectionPolicy(config.Policy, config.Priority)
+		if err != nil {
+			s.selecting.Unlock()
+			return err
+		}
+		s.policy = policy
+	}
+	s.config = config
+	config.Apply(s.client)
+	s.selecting.Unlock()
+	return nil
+}
+
+// Ask the selection policy which player to follow after an event. Players are
+// asked about their state before the policy, so a slow one doesn't hold up
+// other selections.
+func (s *State) selectPlayer(event musicwand.SelectionEvent) {
+	// Only list the players again when they may have changed.
+	var players []mpris.Player
+	relist := event.Reason != musicwand.SelectPlayerChanged && event.Reason != musicwand.SelectManual
+	if relist {
+		for _, player := range s.client.Players() {
+			if !s.isSelf(&player) {
+				players = append(players, player)
+			}
+		}
+	} else {
+		players = s.players()
+	}
+	if len(players) == 0 {
+		log.Println("Unable to connect to any music players")
+	}
+	event.Statuses = playbackStatuses(players, event)
+
+	s.selecting.Lock()
+	if relist {
+		s.list = append([]mpris.Player(nil), players...)
+	}
+	changed := false
+	if player, ok := s.decide(players, event); ok {
+		changed = s.setPlayer(player)
+	}
+	s.selecting.Unlock()
+
+	if changed {
+		s.announce()
+	}
+}
+
+// Get the player the policy picks after an event, or false if the event
+// should be ignored. Must be called with the selecting lock held.
+func (s *State) decide(players []mpris.Player, event musicwand.SelectionEvent) (*mpris.Player, bool) {
+	// Ignored players don't get a say.
+	found := event.Player == nil
+	for i := range players {
+		found = found || samePlayer(event.Player, &players[i])
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Only offer the current player if it's still running.
+	current := s.currentPlayer()
+	found = false
+	for i := range players {
+		found = found || samePlayer(current, &players[i])
+	}
+	if !found {
+		current = nil
+	}
+	return s.policy.Select(current, players, event), true
+}
+
+// Get every player which can be followed, in priority order, as of the last
+// time a player started or exited.
+func (s *State) players() []mpris.Player {
+	s.selecting.Lock()
+	defer s.selecting.Unlock()
+	return append([]mpris.Player(nil), s.list...)
+}
+
+// Ask each player for its playback status, by bus name. The player an event
+// is about already said what its status is if it changed.
+func playbackStatuses(players []mpris.Player, event musicwand.SelectionEvent) map[string]mpris.PlaybackState {
+	statuses := make(map[string]mpris.PlaybackState, len(players))
+	for i := range players {
+		statuses[players[i].Name] = players[i].PlaybackStatus()
+	}
+	if status, ok := event.Changed["PlaybackStatus"].(mpris.PlaybackState); ok {
+		statuses[event.Player.Name] = status
+	}
+	return statuses
+}
+
+// Check if two players are the same running process.
+func samePlayer(a, b *mpris.Player) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.Owner == b.Owner
 }
 
 //
@@ -182,11 +510,60 @@ func (s *State) selectPlayer() {
 //
 // The process to perform the work of music wand
 //
+const (
+	// The bus name the daemon's proxy object is published under.
+	proxyName = "org.mpris.MediaPlayer2.musicwand"
+
+	// The interface for controlling the daemon itself.
+	daemonInterface = "com.github.shreve.musicwand"
+
+	// Errors the daemon answers with.
+	errNotFound = daemonInterface + ".Error.NotFound"
+	errRefused  = daemonInterface + ".Error.Refused"
+)
+
+// Call a method on the running daemon's own interface.
+func callDaemon(timeout time.Duration, method string, args ...interface{}) *dbus.Call {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return &dbus.Call{Err: err}
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	obj := conn.Object(proxyName, "/org/mpris/MediaPlayer2")
+	return obj.CallWithContext(ctx, daemonInterface+"."+method, 0, args...)
+}
+
 func StartDaemon() {
 	exec.Command(os.Args[0], "daemon").Start()
 }
 
-func RunDaemon() {
+func RunDaemon(args []string) {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	policyName := flags.String("policy", "", "How to choose the player to control: recent, sticky, priority or manual")
+	priority := flags.String("priority", "", "Comma-separated player names, most preferred first, for the priority policy")
+	flags.Parse(args)
+
+	// Flags win over the config file, even after it's reloaded.
+	override := func(config musicwand.Config) musicwand.Config {
+		if *policyName != "" {
+			config.Policy = *policyName
+		}
+		if *priority != "" {
+			config.Priority = strings.Split(*priority, ",")
+		}
+		return config
+	}
+	configPath := musicwand.ConfigPath()
+	config, err := musicwand.LoadConfig(configPath)
+	if err != nil {
+		log.Println("Unable to load config:", err)
+	}
+
 	server, err := mpris.NewServer("musicwand")
 	if err != nil {
 		log.Fatal(err)
@@ -197,30 +574,91 @@ func RunDaemon() {
 		log.Fatal(err)
 	}
 
-	state := State{client: *client}
-	state.selectPlayer()
+	state := State{client: client, server: server}
+	if err := state.setConfig(override(config)); err != nil {
+		log.Fatal(err)
+	}
+	state.selectPlayer(musicwand.SelectionEvent{Reason: musicwand.SelectStartup})
 
-	player := state.CurrentPlayer
+	server.PropertyHandler = &propertyHandler{&state}
+	server.AppServer = &appServer{&state}
+	server.PlayerServer = &playerServer{&state}
+
+	if err := server.AddInterface(daemonInterface, &state); err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		events, err := client.OnAnyPlayerChange()
+		if err != nil {
+			log.Println("Unable to watch for changes:", err)
+			return
+		}
+		for event := range events {
+			if state.isSelf(event.Player) {
+				continue
+			}
+			if current := state.currentPlayer(); current != nil && current.Owner == event.Player.Owner {
+				state.refresh(event.Interface)
+			}
+			// Policies only react to players starting and stopping.
+			if _, ok := event.Changed["PlaybackStatus"]; !ok {
+				continue
+			}
+			state.selectPlayer(musicwand.SelectionEvent{
+				Reason:  musicwand.SelectPlayerChanged,
+				Player:  event.Player,
+				Changed: event.Changed,
+			})
+		}
+	}()
 
-	server.PropertyHandler = &propertyHandler{player}
-	server.AppServer = &appServer{player}
-	server.PlayerServer = &playerServer{player}
+	go func() {
+		events, err := client.WatchPlayers()
+		if err != nil {
+			log.Println("Unable to watch for players:", err)
+			return
+		}
+		for event := range events {
+			if event.Name == server.BusName() {
+				continue
+			}
+			log.Println("Player", event.Type, event.Name)
+			reason := musicwand.SelectPlayerAdded
+			if event.Type == mpris.PlayerRemoved {
+				reason = musicwand.SelectPlayerRemoved
+			}
+			state.selectPlayer(musicwand.SelectionEvent{Reason: reason, Player: event.Player})
+		}
+	}()
 
-	server.AddInterface("com.github.shreve.musicwand", &state)
+	// Stop cleanly when asked to, releasing our name for the next daemon.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
 
 	go func() {
-		events, _ := client.OnAnyPlayerChange()
-		for {
-			event := <-events
-			player := client.PlayerWithOwner(event.Sender)
-			if player != nil {
-				state.setPlayer(player)
-				log.Println(*state.CurrentPlayer)
+		for config := range musicwand.WatchConfig(ctx, configPath, 2*time.Second) {
+			log.Println("Reloading config")
+			if err := state.setConfig(override(config)); err != nil {
+				log.Println("Unable to use config:", err)
+				continue
 			}
+			state.selectPlayer(musicwand.SelectionEvent{Reason: musicwand.SelectConfigChanged})
 		}
 	}()
 
-	if err := server.Listen(); err != nil {
+	err = server.Serve(ctx)
+	if errors.Is(err, mpris.ErrNameLost) {
+		log.Println("Another daemon has taken over, exiting")
+		return
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
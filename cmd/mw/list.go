@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// What mw list shows about each player.
+type listing struct {
+	Name           string              `json:"name"`
+	Identity       string              `json:"identity"`
+	DesktopEntry   string              `json:"desktopEntry"`
+	Owner          string              `json:"owner"`
+	PlaybackStatus mpris.PlaybackState `json:"playbackStatus"`
+	Artist         string              `json:"artist"`
+	Title          string              `json:"title"`
+	Selected       bool                `json:"selected"`
+}
+
+// Get the current track as one string, like "Artist - Title".
+func (l listing) Track() string {
+	if l.Artist == "" {
+		return l.Title
+	}
+	return l.Artist + " - " + l.Title
+}
+
+// Load what mw list shows for each player. Players which don't answer are
+// listed with only their names.
+func listPlayers(players []mpris.Player, selected string) []listing {
+	listings := make([]listing, 0, len(players))
+	for _, player := range players {
+		state, _ := player.GetSnapshot()
+		listings = append(listings, listing{
+			Name:           player.Name,
+			Identity:       state.Identity,
+			DesktopEntry:   state.DesktopEntry,
+			Owner:          player.Owner,
+			PlaybackStatus: state.PlaybackStatus,
+			Artist:         strings.Join(state.Metadata.Artist, ", "),
+			Title:          state.Metadata.Title,
+			Selected:       player.Name == selected,
+		})
+	}
+	return listings
+}
+
+// Print players as a table, marking the selected one.
+func printListTable(out io.Writer, listings []listing) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNAME\tIDENTITY\tDESKTOP ENTRY\tOWNER\tSTATUS\tTRACK")
+	for _, l := range listings {
+		marker := ""
+		if l.Selected {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			marker, l.Name, l.Identity, l.DesktopEntry, l.Owner, l.PlaybackStatus, l.Track())
+	}
+	return w.Flush()
+}
+
+// Print players as a JSON array.
+func printListJSON(out io.Writer, listings []listing) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(listings)
+}
+
+// Print one line per player using a text/template, like "{{.Name}} {{.Track}}".
+func printListFormat(out io.Writer, listings []listing, format string) error {
+	tmpl, err := template.New("list").Parse(format)
+	if err != nil {
+		return err
+	}
+	for _, l := range listings {
+		if err := tmpl.Execute(out, l); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
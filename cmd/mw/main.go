@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/shreve/musicwand/internal/pkg/musicwand"
@@ -13,12 +14,40 @@ import (
 
 func main() {
 	if len(os.Args) > 1 && os.Args[1] == "daemon" {
-		RunDaemon()
+		RunDaemon(os.Args[2:])
 		os.Exit(0)
 	}
 
 	var client *mpris.Client
-	var player *mpris.Player
+	var player *mpris.Player   // The player to read from
+	var targets []mpris.Player // Every player to send commands to
+	var config musicwand.Config
+
+	// Send a command to every targeted player, reporting each failure.
+	each := func(command func(p *mpris.Player) error) error {
+		if len(targets) == 1 {
+			return command(&targets[0])
+		}
+		failed := 0
+		for i := range targets {
+			if err := command(&targets[i]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", targets[i].Name, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d players failed", failed, len(targets))
+		}
+		return nil
+	}
+
+	// Use the format from the command line, or else the configured one.
+	statusFormat := func(c *cli.Context, state mpris.Snapshot) string {
+		if c.IsSet("format") {
+			return c.String("format")
+		}
+		return config.StatusFormat(state, c.String("format"))
+	}
 
 	cliApp := cli.App{
 		Name:  "mw",
@@ -29,6 +58,44 @@ func main() {
 				fmt.Fprintf(os.Stderr, err.Error())
 				os.Exit(1)
 			}
+			client.Timeout = c.Duration("timeout")
+
+			config, err = musicwand.LoadConfig(musicwand.ConfigPath())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to load config: %v\n", err)
+			}
+			config.Apply(client)
+
+			// Talk to players directly when asked to, instead of the daemon.
+			name := config.Resolve(c.String("player"))
+			switch {
+			case c.Bool("all"):
+				var players []mpris.Player
+				if c.IsSet("player") {
+					players = client.FindPlayers(name)
+				} else {
+					players = client.Players()
+				}
+				for _, found := range players {
+					if found.Name != proxyName {
+						targets = append(targets, found)
+					}
+				}
+				if len(targets) == 0 {
+					fmt.Fprintf(os.Stderr, "No players found.\n")
+					os.Exit(1)
+				}
+				player = &targets[0]
+				return nil
+			case c.IsSet("player"):
+				player = client.FindPlayer(name)
+				if player == nil {
+					fmt.Fprintf(os.Stderr, "No player matches %q.\n", c.String("player"))
+					os.Exit(1)
+				}
+				targets = []mpris.Player{*player}
+				return nil
+			}
 
 			player = client.FindPlayer("musicwand")
 			if player == nil {
@@ -50,6 +117,7 @@ func main() {
 				fmt.Fprintf(os.Stderr, "Couldn't start the musicwand daemon.\n")
 				os.Exit(1)
 			}
+			targets = []mpris.Player{*player}
 
 			return nil
 		},
@@ -58,7 +126,19 @@ func main() {
 			return nil
 		},
 		Flags: []cli.Flag{
-			&cli.StringFlag{Name: "player"},
+			&cli.StringFlag{
+				Name:  "player",
+				Usage: "Control a player directly, by bus name or identity. Accepts globs like chrom* and regular expressions like /^vlc/",
+			},
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "Send commands to every player, or every one matching --player",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "How long to wait for a player to respond",
+				Value: mpris.DefaultTimeout,
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -66,9 +146,7 @@ func main() {
 				Aliases: []string{"y"},
 				Usage:   "Instruct the player to play",
 				Action: func(c *cli.Context) error {
-					fmt.Println("player.Play()")
-					player.Play()
-					return nil
+					return each((*mpris.Player).Play)
 				},
 			},
 			{
@@ -76,8 +154,7 @@ func main() {
 				Aliases: []string{"u"},
 				Usage:   "Instruct the player to pause",
 				Action: func(c *cli.Context) error {
-					player.Pause()
-					return nil
+					return each((*mpris.Player).Pause)
 				},
 			},
 			{
@@ -85,8 +162,7 @@ func main() {
 				Aliases: []string{"p"},
 				Usage:   "Instruct the player to play or pause based on current state",
 				Action: func(c *cli.Context) error {
-					player.PlayPause()
-					return nil
+					return each((*mpris.Player).PlayPause)
 				},
 			},
 			{
@@ -94,8 +170,7 @@ func main() {
 				Aliases: []string{"n"},
 				Usage:   "Instruct the player to play the next media",
 				Action: func(c *cli.Context) error {
-					player.Next()
-					return nil
+					return each((*mpris.Player).Next)
 				},
 			},
 			{
@@ -103,8 +178,7 @@ func main() {
 				Aliases: []string{"prev", "v"},
 				Usage:   "Instruct the player to play the previous media",
 				Action: func(c *cli.Context) error {
-					player.Previous()
-					return nil
+					return each((*mpris.Player).Previous)
 				},
 			},
 			{
@@ -112,8 +186,7 @@ func main() {
 				Aliases: []string{"s"},
 				Usage:   "Instruct the player to stop",
 				Action: func(c *cli.Context) error {
-					player.Stop()
-					return nil
+					return each((*mpris.Player).Stop)
 				},
 			},
 			{
@@ -122,8 +195,163 @@ func main() {
 				Usage:     "Instruct the player to open the provided URI",
 				ArgsUsage: "[URI]",
 				Action: func(c *cli.Context) error {
-					player.OpenUri(c.Args().Get(0))
-					return nil
+					return each(func(p *mpris.Player) error {
+						return p.OpenUri(c.Args().Get(0))
+					})
+				},
+			},
+			{
+				Name:            "volume",
+				Usage:           "Get or set the volume, like 0.5, +0.1 or -10%",
+				ArgsUsage:       "[VALUE]",
+				SkipFlagParsing: true, // So -10% isn't read as a flag
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						volume, err := player.GetVolume()
+						if err != nil {
+							return err
+						}
+						fmt.Printf("%.2f\n", volume)
+						return nil
+					}
+					change, err := parseAdjustment(c.Args().First())
+					if err != nil {
+						return err
+					}
+					return each(func(p *mpris.Player) error {
+						if err := requireControl(p); err != nil {
+							return err
+						}
+						volume, err := p.GetVolume()
+						if err != nil {
+							return err
+						}
+						return p.SetVolume(change.apply(volume, 0, 1))
+					})
+				},
+			},
+			{
+				Name:            "position",
+				Usage:           "Get or set the position in the track, like 1:23, +5s or -10",
+				ArgsUsage:       "[POSITION]",
+				SkipFlagParsing: true,
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						position, err := player.GetPosition()
+						if err != nil {
+							return err
+						}
+						fmt.Println(musicwand.FormatTime(position))
+						return nil
+					}
+					offset, relative, err := parsePosition(c.Args().First())
+					if err != nil {
+						return err
+					}
+					return each(func(p *mpris.Player) error {
+						return seekTo(p, offset, relative)
+					})
+				},
+			},
+			{
+				Name:      "shuffle",
+				Usage:     "Get or set whether the player shuffles: on, off or toggle",
+				ArgsUsage: "[on|off|toggle]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						shuffle, err := player.GetShuffle()
+						if err != nil {
+							return err
+						}
+						if shuffle {
+							fmt.Println("on")
+						} else {
+							fmt.Println("off")
+						}
+						return nil
+					}
+					if _, err := parseShuffle(c.Args().First(), false); err != nil {
+						return err
+					}
+					return each(func(p *mpris.Player) error {
+						if err := requireControl(p); err != nil {
+							return err
+						}
+						current, err := p.GetShuffle()
+						if err != nil {
+							return err
+						}
+						shuffle, _ := parseShuffle(c.Args().First(), current)
+						return p.SetShuffle(shuffle)
+					})
+				},
+			},
+			{
+				Name:      "loop",
+				Usage:     "Get or set how the player loops: none, track, playlist or cycle",
+				ArgsUsage: "[none|track|playlist|cycle]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						loop, err := player.GetLoopStatus()
+						if err != nil {
+							return err
+						}
+						fmt.Println(strings.ToLower(string(loop)))
+						return nil
+					}
+					if strings.EqualFold(c.Args().First(), "cycle") {
+						return each(func(p *mpris.Player) error {
+							if err := requireControl(p); err != nil {
+								return err
+							}
+							_, err := p.CycleLoopStatus()
+							return err
+						})
+					}
+					loop, err := parseLoop(c.Args().First())
+					if err != nil {
+						return err
+					}
+					return each(func(p *mpris.Player) error {
+						if err := requireControl(p); err != nil {
+							return err
+						}
+						return p.SetLoopStatus(loop)
+					})
+				},
+			},
+			{
+				Name:            "rate",
+				Usage:           "Get or set the playback speed, like 1.5 or +0.25",
+				ArgsUsage:       "[VALUE]",
+				SkipFlagParsing: true,
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						rate, err := player.GetRate()
+						if err != nil {
+							return err
+						}
+						fmt.Printf("%.2f\n", rate)
+						return nil
+					}
+					change, err := parseAdjustment(c.Args().First())
+					if err != nil {
+						return err
+					}
+					return each(func(p *mpris.Player) error {
+						if err := requireControl(p); err != nil {
+							return err
+						}
+						state, err := p.GetSnapshot()
+						if err != nil {
+							return err
+						}
+						rate, err := adjustRate(change, state)
+						if err != nil {
+							return err
+						}
+						return p.SetRate(rate)
+					})
 				},
 			},
 			{
@@ -142,11 +370,108 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:    "list",
+				Aliases: []string{"l"},
+				Usage:   "List every running player",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the list as JSON",
+					},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "Print each player with a template, like '{{.Name}} {{.PlaybackStatus}} {{.Track}}'",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var players []mpris.Player
+					if c.IsSet("player") {
+						players = client.FindPlayers(config.Resolve(c.String("player")))
+					} else {
+						players = client.Players()
+					}
+					for i := 0; i < len(players); i++ {
+						if players[i].Name == proxyName {
+							players = append(players[:i], players[i+1:]...)
+							i--
+						}
+					}
+
+					// The daemon may not be running when using --player.
+					var selected string
+					callDaemon(client.Timeout, "CurrentPlayer").Store(&selected)
+
+					listings := listPlayers(players, selected)
+					switch {
+					case c.Bool("json"):
+						return printListJSON(os.Stdout, listings)
+					case c.IsSet("format"):
+						return printListFormat(os.Stdout, listings, c.String("format"))
+					default:
+						return printListTable(os.Stdout, listings)
+					}
+				},
+			},
+			{
+				Name:      "use",
+				Usage:     "Make the daemon control the named player",
+				ArgsUsage: "[NAME]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("Which player should be used?")
+					}
+					var name string
+					err := callDaemon(client.Timeout, "SetCurrentPlayer", config.Resolve(c.Args().First())).Store(&name)
+					if err != nil {
+						return err
+					}
+					fmt.Println(name)
+					return nil
+				},
+			},
+			{
+				Name:  "current",
+				Usage: "Show which player the daemon is controlling",
+				Action: func(c *cli.Context) error {
+					var name string
+					if err := callDaemon(client.Timeout, "CurrentPlayer").Store(&name); err != nil {
+						return err
+					}
+					if name == "" {
+						return fmt.Errorf("No player is being controlled")
+					}
+					fmt.Println(name)
+					return nil
+				},
+			},
+			{
+				Name:  "cycle",
+				Usage: "Make the daemon control the next running player",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "reverse",
+						Aliases: []string{"r"},
+						Usage:   "Go to the previous player instead",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var name string
+					if err := callDaemon(client.Timeout, "CyclePlayer", c.Bool("reverse")).Store(&name); err != nil {
+						return err
+					}
+					fmt.Println(name)
+					return nil
+				},
+			},
 			{
 				Name:  "daemon",
 				Usage: "Run the musicwand control daemon",
+				// Flags are parsed by the daemon itself.
+				SkipFlagParsing: true,
 				Action: func(c *cli.Context) error {
-					RunDaemon()
+					RunDaemon(c.Args().Slice())
 					return nil
 				},
 			},
@@ -154,11 +479,21 @@ func main() {
 				Name:  "watch",
 				Usage: "Tail a log of events monitored by this library",
 				Action: func(c *cli.Context) error {
-					events, _ := client.OnAnyPlayerChange()
+					players, err := client.WatchPlayers()
+					if err != nil {
+						return err
+					}
+					events, err := client.OnAnyPlayerChange()
+					if err != nil {
+						return err
+					}
 					for {
-						event := <-events
-						player := client.PlayerWithOwner(event.Sender)
-						fmt.Println("CHANGE", player.Name, event.Body[1])
+						select {
+						case event := <-players:
+							fmt.Println(strings.ToUpper(string(event.Type)), event.Name, event.NewOwner)
+						case event := <-events:
+							fmt.Println("CHANGE", event.Player.Name, event.Changed)
+						}
 					}
 				},
 			},
@@ -174,32 +509,102 @@ func main() {
 					&cli.StringFlag{
 						Name:    "format",
 						Aliases: []string{"f"},
-						Usage:   "Provide a format string to be filled in with data",
+						Usage:   "Provide a format string to be filled in with data, using {placeholders} or a Go template like '{{.Artist | upper}}'",
 						Value:   "{icon} {artist} :: {track}",
 					},
+					&cli.DurationFlag{
+						Name:    "interval",
+						Aliases: []string{"i"},
+						Usage:   "While watching, also redraw this often to keep {position} moving",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Write the status for a bar: plain, waybar, i3bar, polybar, i3blocks or tmux",
+						Value:   "plain",
+					},
 				},
 				Action: func(c *cli.Context) error {
-					if player != nil {
-						fmt.Println(musicwand.FormatStatus(c.String("format"), player))
-					}
-					if c.Bool("watch") {
-						events, _ := client.OnAnyPlayerChange()
-						for {
-							event := <-events
-							player := client.PlayerWithOwner(event.Sender)
-							if player != nil {
-								fmt.Println(musicwand.FormatStatus(c.String("format"), player))
+					if format := c.String("format"); musicwand.IsTemplate(format) {
+						if _, err := musicwand.ParseStatusTemplate(format); err != nil {
+							return err
+						}
+					}
+
+					// Bar click actions should control the same players.
+					command := shellQuote(os.Args[0])
+					if c.IsSet("player") {
+						command += " --player " + shellQuote(c.String("player"))
+					}
+					if c.Bool("all") {
+						command += " --all"
+					}
+					output, err := musicwand.NewStatusOutput(c.String("output"), command)
+					if err != nil {
+						return err
+					}
+					if err := output.Begin(os.Stdout); err != nil {
+						return err
+					}
+
+					if !c.Bool("watch") {
+						state, err := player.GetSnapshot()
+						if err != nil {
+							return err
+						}
+						line := config.Formatter().Format(statusFormat(c, state), state)
+						return output.Write(os.Stdout, line, state)
+					}
+
+					// Stay with the same player, reading its state from a cache
+					// instead of asking for each placeholder. The daemon's proxy
+					// announces changes itself whenever it follows another player.
+					cached, err := mpris.NewCachedPlayer(player)
+					if err != nil {
+						return err
+					}
+					defer cached.Close()
+
+					// The position is estimated from the cache, so ticking
+					// doesn't poll the player. Only print when the line changes.
+					var tick <-chan time.Time
+					if interval := c.Duration("interval"); interval > 0 {
+						ticker := time.NewTicker(interval)
+						defer ticker.Stop()
+						tick = ticker.C
+					}
+					last := ""
+					for {
+						select {
+						case <-tick:
+							state := cached.Snapshot()
+							line := config.Formatter().Format(statusFormat(c, state), state)
+							if line != last {
+								if err := output.Write(os.Stdout, line, state); err != nil {
+									return err
+								}
+								last = line
+							}
+						case <-cached.Updates():
+							state := cached.Snapshot()
+							last = config.Formatter().Format(statusFormat(c, state), state)
+							if err := output.Write(os.Stdout, last, state); err != nil {
+								return err
 							}
 						}
 					}
-					return nil
 				},
 			},
 		},
 	}
 
 	if err := cliApp.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 }
+
+// Quote an argument so the shell passes it along unchanged.
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
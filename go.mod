@@ -3,6 +3,7 @@ module github.com/shreve/musicwand
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/godbus/dbus/v5 v5.0.3
 	github.com/urfave/cli/v2 v2.3.0
 )
@@ -0,0 +1,189 @@
+package musicwand
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// Settings shared by mw and the daemon, read from config.toml.
+//
+//   policy = "priority"
+//   priority = ["spotify", "vlc"]
+//   ignore = ["firefox", "chromium"]
+//
+//   [aliases]
+//   music = "spotify"
+//
+//   [formats]
+//   default = "{icon} {artist} :: {track}"
+//   spotify = "{artist} - {track}"
+//
+//   [fallbacks]
+//   artist = "Unknown artist"
+type Config struct {
+	// The selection policy used by the daemon. See NewSelectionPolicy.
+	Policy string `toml:"policy"`
+
+	// Players to prefer, most preferred first.
+	Priority []string `toml:"priority"`
+
+	// Players to pretend aren't running.
+	Ignore []string `toml:"ignore"`
+
+	// Other names to refer to players by, mapped to the player's name.
+	Aliases map[string]string `toml:"aliases"`
+
+	// Status formats by player name, with "default" used for the rest.
+	Formats map[string]string `toml:"formats"`
+
+	// What to show for status placeholders the player has nothing for, by
+	// placeholder name.
+	Fallbacks map[string]string `toml:"fallbacks"`
+}
+
+// Get the location of the config file, following the XDG base directory spec.
+func ConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "musicwand", "config.toml")
+}
+
+// Read the config file at path. A missing file is the same as an empty one.
+// Status formats which are templates are checked, and the config is returned
+// along with the error if one doesn't parse.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	_, err := toml.DecodeFile(path, &config)
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	for name, format := range config.Formats {
+		if !IsTemplate(format) {
+			continue
+		}
+		if _, err := ParseStatusTemplate(format); err != nil {
+			return config, fmt.Errorf("Invalid format for %s: %v", name, err)
+		}
+	}
+	return config, nil
+}
+
+// Make a client skip ignored players and list the others in priority order.
+func (c Config) Apply(client *mpris.Client) {
+	client.SetIgnore(c.Ignore)
+	client.SetPriority(c.Priority)
+}
+
+// Get the player name an alias refers to. Names that aren't aliases are
+// returned as they are.
+func (c Config) Resolve(name string) string {
+	if target, ok := c.Aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// Get the status format for a player, identified by its desktop entry or
+// identity, in that order. Returns fallback if neither the player nor a
+// default is configured.
+func (c Config) StatusFormat(state mpris.Snapshot, fallback string) string {
+	for _, name := range []string{state.DesktopEntry, state.Identity} {
+		if format, ok := c.format(name); ok {
+			return format
+		}
+	}
+	if format, ok := c.Formats["default"]; ok {
+		return format
+	}
+	return fallback
+}
+
+// Get the format configured for a player name, ignoring case. An exact match
+// wins over one which only differs in case.
+func (c Config) format(name string) (string, bool) {
+	if name == "" || name == "default" {
+		return "", false
+	}
+	if format, ok := c.Formats[name]; ok {
+		return format, true
+	}
+	names := make([]string, 0, len(c.Formats))
+	for key := range c.Formats {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		if key != "default" && strings.EqualFold(key, name) {
+			return c.Formats[key], true
+		}
+	}
+	return "", false
+}
+
+// Get a status formatter using the configured fallbacks.
+func (c Config) Formatter() StatusFormatter {
+	return StatusFormatter{Fallbacks: c.Fallbacks}
+}
+
+// Watch the config file and send the new config whenever it changes. The file
+// is checked every interval, and the channel is closed when ctx is done. Files
+// which fail to load, including ones with broken templates, are logged to
+// stderr and skipped.
+func WatchConfig(ctx context.Context, path string, interval time.Duration) <-chan Config {
+	configs := make(chan Config)
+	go func() {
+		defer close(configs)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := modTime(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := modTime(path)
+			if current.Equal(last) {
+				continue
+			}
+			last = current
+			config, err := LoadConfig(path)
+			if err != nil {
+				log.Println("Unable to load config:", err)
+				continue
+			}
+			select {
+			case configs <- config:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return configs
+}
+
+// Get when a file was last modified, or the zero time if it doesn't exist.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
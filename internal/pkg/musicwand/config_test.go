@@ -0,0 +1,43 @@
+package musicwand
+
+import (
+	"testing"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+func TestConfigStatusFormat(t *testing.T) {
+	config := Config{Formats: map[string]string{
+		"default": "default",
+		"spotify": "by entry",
+		"Spotify": "by Entry",
+		"VLC":     "by identity",
+		"firefox": "entry wins",
+		"Firefox": "identity loses",
+	}}
+
+	tests := []struct {
+		entry, identity string
+		want            string
+	}{
+		{"spotify", "", "by entry"},
+		{"Spotify", "", "by Entry"},
+		{"SPOTIFY", "", "by Entry"},
+		{"vlc", "", "by identity"},
+		{"", "vlc media player", "default"},
+		{"org.videolan", "vlc", "by identity"},
+		{"firefox", "Firefox", "entry wins"},
+		{"", "", "default"},
+		{"default", "", "default"},
+	}
+	for _, test := range tests {
+		state := mpris.Snapshot{DesktopEntry: test.entry, Identity: test.identity}
+		if got := config.StatusFormat(state, "fallback"); got != test.want {
+			t.Errorf("StatusFormat(%q, %q) = %q, want %q", test.entry, test.identity, got, test.want)
+		}
+	}
+
+	if got := (Config{}).StatusFormat(mpris.Snapshot{Identity: "VLC"}, "fallback"); got != "fallback" {
+		t.Errorf("StatusFormat without formats = %q, want %q", got, "fallback")
+	}
+}
@@ -2,62 +2,153 @@ package musicwand
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/shreve/musicwand/pkg/mpris"
 )
 
-func FormatStatus(template string, player *mpris.Player) string {
-	findAndReplace(&template, "{status}", func() string {
-		return string(player.PlaybackStatus())
-	})
-
-	findAndReplace(&template, "{artist}", func() string {
-		return player.RawMetadata()["xesam:artist"].Value().([]string)[0]
-	})
-
-	findAndReplace(&template, "{album}", func() string {
-		return player.RawMetadata()["xesam:album"].Value().([]string)[0]
-	})
-
-	findAndReplace(&template, "{track}", func() string {
-		return player.RawMetadata()["xesam:title"].Value().(string)
-	})
-
-	findAndReplace(&template, "{length}", func() string {
-		microseconds := player.RawMetadata()["mpris:length"].Value()
-		switch microseconds.(type) {
-		case int64:
-			return formatTime(microseconds.(int64))
-		case uint64:
-			return formatTime(int64(microseconds.(uint64)))
+// The placeholders in status formats, like {artist}, and how to fill them in.
+// Anything missing from the player is left empty.
+var placeholders = map[string]func(state mpris.Snapshot) string{
+	"status": func(state mpris.Snapshot) string {
+		return string(state.PlaybackStatus)
+	},
+	"artist": func(state mpris.Snapshot) string {
+		return strings.Join(state.Metadata.Artist, ", ")
+	},
+	"album": func(state mpris.Snapshot) string {
+		return state.Metadata.Album
+	},
+	"track": func(state mpris.Snapshot) string {
+		return state.Metadata.Title
+	},
+	"length": func(state mpris.Snapshot) string {
+		if state.Metadata.Length <= 0 {
+			return ""
 		}
-		return ""
-	})
+		return FormatTime(state.Metadata.Length)
+	},
+	"position": func(state mpris.Snapshot) string {
+		return FormatTime(state.EstimatedPosition())
+	},
+	"icon": func(state mpris.Snapshot) string {
+		return string(Icon(state.Identity))
+	},
+}
+
+var placeholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Fills in status formats. Placeholders with nothing to show use the fallback
+// for their name, like "artist", if there is one. Otherwise they're dropped
+// along with the text touching them and a separator, so "{icon} {artist} ::
+// {track}" without an artist becomes "{icon} {track}", and "{track} ({album})"
+// without an album becomes "{track}".
+type StatusFormatter struct {
+	Fallbacks map[string]string
+}
+
+// Fill in a status format with the state of a player. Formats containing {{
+// are Go templates, see StatusData, and show their error if they fail. Others
+// have placeholders like {artist} replaced.
+func FormatStatus(format string, state mpris.Snapshot) string {
+	return StatusFormatter{}.Format(format, state)
+}
+
+// Fill in a status format with the state of a player, as in FormatStatus.
+// Fallbacks only apply to placeholders; templates can use default instead.
+func (f StatusFormatter) Format(format string, state mpris.Snapshot) string {
+	if IsTemplate(format) {
+		line, err := RenderStatusTemplate(format, state)
+		if err != nil {
+			return err.Error()
+		}
+		return line
+	}
+
+	// Split the format into placeholders and the text between them.
+	var segments []segment
+	var text []string
+	last := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(format, -1) {
+		name := format[match[2]:match[3]]
+		fill, ok := placeholders[name]
+		if !ok {
+			continue
+		}
+		value := fill(state)
+		if value == "" {
+			value = f.Fallbacks[name]
+		}
+		segments = append(segments, segment{value: value})
+		text = append(text, format[last:match[0]])
+		last = match[1]
+	}
+	text = append(text, format[last:])
+	if len(segments) == 0 {
+		return format
+	}
 
-	findAndReplace(&template, "{position}", func() string {
-		fmt.Println(player.Position())
-		return formatTime(player.Position())
-	})
+	// Text touching a placeholder belongs to it, like the brackets in "[{status}]".
+	// The rest of the text between two placeholders separates them.
+	var head, tail string
+	head, segments[0].prefix = splitTrailing(text[0])
+	for i := 1; i < len(segments); i++ {
+		between := text[i]
+		if !strings.ContainsAny(between, " \t") {
+			segments[i-1].separator = between
+			continue
+		}
+		segments[i-1].suffix, between = splitLeading(between)
+		segments[i-1].separator, segments[i].prefix = splitTrailing(between)
+	}
+	segments[len(segments)-1].suffix, tail = splitLeading(text[len(text)-1])
+
+	// Only the first separator after each shown placeholder is kept.
+	var out strings.Builder
+	out.WriteString(head)
+	shown, separator := false, ""
+	for _, seg := range segments {
+		if seg.value == "" {
+			continue
+		}
+		if shown {
+			out.WriteString(separator)
+		}
+		out.WriteString(seg.prefix + seg.value + seg.suffix)
+		shown, separator = true, seg.separator
+	}
+	out.WriteString(tail)
+	return out.String()
+}
 
-	findAndReplace(&template, "{icon}", func() string {
-		return string(Icon(player.Identity()))
-	})
+// A placeholder in a status format, with the text attached to it.
+type segment struct {
+	prefix, value, suffix string
+	separator             string // Text between this placeholder and the next
+}
+
+// Split text before its trailing run of non-space characters.
+func splitTrailing(text string) (rest, trailing string) {
+	i := strings.LastIndexAny(text, " \t") + 1
+	return text[:i], text[i:]
+}
 
-	return template
+// Split text after its leading run of non-space characters.
+func splitLeading(text string) (leading, rest string) {
+	i := strings.IndexAny(text, " \t")
+	if i < 0 {
+		return text, ""
+	}
+	return text[:i], text[i:]
 }
 
-func formatTime(duration int64) string {
+// Format a time in microseconds like 1:23.
+func FormatTime(duration int64) string {
 	length := time.Duration(duration) * time.Microsecond
 	minutes := int(length.Minutes())
 	length -= time.Duration(minutes) * time.Minute
 	seconds := int(length.Seconds())
 	return fmt.Sprintf("%d:%02d", minutes, seconds)
 }
-
-func findAndReplace(source *string, query string, cb func() string) {
-	if strings.Contains(*source, query) {
-		*source = strings.ReplaceAll(*source, query, cb())
-	}
-}
@@ -0,0 +1,50 @@
+package musicwand
+
+import (
+	"testing"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+func TestStatusFormatterFormat(t *testing.T) {
+	icon := string(Icon(""))
+	full := mpris.Snapshot{Metadata: mpris.Metadata{
+		Artist: []string{"Artist"},
+		Album:  "Album",
+		Title:  "Track",
+	}}
+	noArtist := mpris.Snapshot{Metadata: mpris.Metadata{Album: "Album", Title: "Track"}}
+	noAlbum := mpris.Snapshot{Metadata: mpris.Metadata{Artist: []string{"Artist"}, Title: "Track"}}
+	noTrack := mpris.Snapshot{Metadata: mpris.Metadata{Artist: []string{"Artist"}, Album: "Album"}}
+	fallbacks := map[string]string{"artist": "Unknown"}
+
+	tests := []struct {
+		format    string
+		state     mpris.Snapshot
+		fallbacks map[string]string
+		want      string
+	}{
+		{"{icon} {artist} :: {track}", full, nil, icon + " Artist :: Track"},
+		{"{icon} {artist} :: {track}", noArtist, nil, icon + " Track"},
+		{"{icon} {artist} :: {track}", noTrack, nil, icon + " Artist"},
+		{"{track} ({album})", full, nil, "Track (Album)"},
+		{"{track} ({album})", noAlbum, nil, "Track"},
+		{"{artist}-{track}", full, nil, "Artist-Track"},
+		{"{artist}-{track}", noArtist, nil, "Track"},
+		{"{artist}-{track}", noTrack, nil, "Artist"},
+		{"[{artist}] {track}", noArtist, nil, "Track"},
+		{"{foo} {track}", full, nil, "{foo} Track"},
+		{"{foo}", full, nil, "{foo}"},
+		{"no placeholders", full, nil, "no placeholders"},
+		{"{icon} {artist} :: {track}", noArtist, fallbacks, icon + " Unknown :: Track"},
+		{"{track} ({album})", noAlbum, fallbacks, "Track"},
+		{"{{.Artist | upper}} - {{.Title}}", full, nil, "ARTIST - Track"},
+		{"{{.Artist | default \"Unknown\"}}", noArtist, fallbacks, "Unknown"},
+	}
+	for _, test := range tests {
+		got := StatusFormatter{Fallbacks: test.fallbacks}.Format(test.format, test.state)
+		if got != test.want {
+			t.Errorf("Format(%q) with %+v = %q, want %q", test.format, test.state.Metadata, got, test.want)
+		}
+	}
+}
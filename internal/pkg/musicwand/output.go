@@ -0,0 +1,211 @@
+package musicwand
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// Writes statuses in the form a status bar reads them.
+type StatusOutput interface {
+	// Write anything the bar expects before the first status.
+	Begin(w io.Writer) error
+
+	// Write one status, given the formatted text and the state it came from.
+	Write(w io.Writer, text string, state mpris.Snapshot) error
+}
+
+// Get an output by name: plain, waybar, i3bar (or swaybar), polybar, i3blocks
+// or tmux. Polybar's click actions run command, like "mw", with a subcommand
+// added.
+func NewStatusOutput(name, command string) (StatusOutput, error) {
+	switch name {
+	case "", "plain":
+		return plainOutput{}, nil
+	case "waybar":
+		return waybarOutput{}, nil
+	case "i3bar", "swaybar":
+		return &i3barOutput{}, nil
+	case "polybar":
+		return polybarOutput{command: command}, nil
+	case "i3blocks":
+		return i3blocksOutput{}, nil
+	case "tmux":
+		return tmuxOutput{}, nil
+	}
+	return nil, fmt.Errorf("Unknown output %q", name)
+}
+
+// One line of text per status.
+type plainOutput struct{}
+
+func (plainOutput) Begin(w io.Writer) error {
+	return nil
+}
+
+func (plainOutput) Write(w io.Writer, text string, state mpris.Snapshot) error {
+	_, err := fmt.Fprintln(w, text)
+	return err
+}
+
+// JSON for a Waybar custom module with return-type set to json. The class and
+// alt are the lowercase playback status, and the percentage is how far through
+// the track the player is. Text is left for Waybar to read as Pango markup, so
+// it's escaped either by the module's escape option or with markup_escape.
+type waybarOutput struct{}
+
+func (waybarOutput) Begin(w io.Writer) error {
+	return nil
+}
+
+func (waybarOutput) Write(w io.Writer, text string, state mpris.Snapshot) error {
+	status := strings.ToLower(string(state.PlaybackStatus))
+	return writeJSON(w, struct {
+		Text       string `json:"text"`
+		Tooltip    string `json:"tooltip"`
+		Class      string `json:"class"`
+		Alt        string `json:"alt"`
+		Percentage int    `json:"percentage"`
+	}{
+		Text:       text,
+		Tooltip:    tooltip(state),
+		Class:      status,
+		Alt:        status,
+		Percentage: progress(state),
+	})
+}
+
+// The i3bar protocol, also used by swaybar: a header, then an endless JSON
+// array with one array of blocks per status.
+type i3barOutput struct {
+	started bool
+}
+
+func (o *i3barOutput) Begin(w io.Writer) error {
+	_, err := fmt.Fprint(w, "{\"version\":1}\n[\n")
+	return err
+}
+
+func (o *i3barOutput) Write(w io.Writer, text string, state mpris.Snapshot) error {
+	if o.started {
+		if _, err := fmt.Fprint(w, ","); err != nil {
+			return err
+		}
+	}
+	o.started = true
+	return writeJSON(w, []i3Block{newI3Block(text, state)})
+}
+
+// A polybar line with action tags: clicking plays or pauses, right clicking
+// skips to the next track, and scrolling changes the volume.
+type polybarOutput struct {
+	command string
+}
+
+func (polybarOutput) Begin(w io.Writer) error {
+	return nil
+}
+
+func (o polybarOutput) Write(w io.Writer, text string, state mpris.Snapshot) error {
+	action := func(button int, subcommand string) string {
+		command := strings.ReplaceAll(o.command+" "+subcommand, ":", "\\:")
+		return fmt.Sprintf("%%{A%d:%s:}", button, command)
+	}
+	_, err := fmt.Fprintln(w,
+		action(1, "play-pause")+
+			action(3, "next")+
+			action(4, "volume +0.05")+
+			action(5, "volume -0.05")+
+			strings.ReplaceAll(text, "%", "%%")+
+			"%{A}%{A}%{A}%{A}")
+	return err
+}
+
+// JSON for an i3blocks block with format set to json.
+type i3blocksOutput struct{}
+
+func (i3blocksOutput) Begin(w io.Writer) error {
+	return nil
+}
+
+func (i3blocksOutput) Write(w io.Writer, text string, state mpris.Snapshot) error {
+	return writeJSON(w, newI3Block(text, state))
+}
+
+// Text for a tmux status line, coloured by the playback status.
+type tmuxOutput struct{}
+
+func (tmuxOutput) Begin(w io.Writer) error {
+	return nil
+}
+
+func (tmuxOutput) Write(w io.Writer, text string, state mpris.Snapshot) error {
+	text = strings.ReplaceAll(text, "#", "##")
+	colour := "default"
+	switch state.PlaybackStatus {
+	case mpris.PlaybackPlaying:
+		colour = "green"
+	case mpris.PlaybackPaused:
+		colour = "yellow"
+	case mpris.PlaybackStopped:
+		colour = "colour244"
+	}
+	_, err := fmt.Fprintf(w, "#[fg=%s]%s#[default]\n", colour, text)
+	return err
+}
+
+// A block in the i3bar and i3blocks protocols.
+type i3Block struct {
+	Name      string `json:"name"`
+	Instance  string `json:"instance,omitempty"`
+	FullText  string `json:"full_text"`
+	ShortText string `json:"short_text,omitempty"`
+	Color     string `json:"color,omitempty"`
+}
+
+// Build a block showing a status, greyed out unless the player is playing.
+func newI3Block(text string, state mpris.Snapshot) i3Block {
+	block := i3Block{
+		Name:      "musicwand",
+		Instance:  state.Identity,
+		FullText:  text,
+		ShortText: state.Metadata.Title,
+	}
+	if state.PlaybackStatus != mpris.PlaybackPlaying {
+		block.Color = "#888888"
+	}
+	return block
+}
+
+// Describe the current track in full, like "Title - Artist (Album)".
+func tooltip(state mpris.Snapshot) string {
+	return StatusFormatter{}.Format("{track} - {artist} ({album})", state)
+}
+
+// Get how far through the track the player is, from 0 to 100.
+func progress(state mpris.Snapshot) int {
+	if state.Metadata.Length <= 0 {
+		return 0
+	}
+	percent := int(state.EstimatedPosition() * 100 / state.Metadata.Length)
+	switch {
+	case percent < 0:
+		return 0
+	case percent > 100:
+		return 100
+	}
+	return percent
+}
+
+// Write a value as JSON on one line.
+func writeJSON(w io.Writer, value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", line)
+	return err
+}
@@ -0,0 +1,211 @@
+package musicwand
+
+import (
+	"fmt"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// Why the daemon is asking which player to follow, implemented as a type to
+// act like an enum.
+type SelectionReason string
+
+const (
+	SelectStartup       SelectionReason = "Startup"
+	SelectPlayerAdded   SelectionReason = "PlayerAdded"
+	SelectPlayerRemoved SelectionReason = "PlayerRemoved"
+	SelectPlayerChanged SelectionReason = "PlayerChanged"
+	SelectConfigChanged SelectionReason = "ConfigChanged"
+	SelectManual        SelectionReason = "Manual"
+)
+
+// Something that happened which might change the selected player.
+type SelectionEvent struct {
+	Reason SelectionReason
+
+	// The player the event is about, or the one the user picked for
+	// SelectManual. Nil on startup, when a player exits, and when the config
+	// changes.
+	Player *mpris.Player
+
+	// The properties which changed, for SelectPlayerChanged. The daemon only
+	// asks about changes which include PlaybackStatus.
+	Changed map[string]interface{}
+
+	// The playback status of each player, by bus name, gathered beforehand so
+	// policies don't need to ask players themselves.
+	Statuses map[string]mpris.PlaybackState
+}
+
+// Get the new playback status from a change, or an empty status if it didn't
+// change.
+func (e SelectionEvent) status() mpris.PlaybackState {
+	status, _ := e.Changed["PlaybackStatus"].(mpris.PlaybackState)
+	return status
+}
+
+// Decides which player the daemon forwards to. Select is called for every
+// event with the current player, which is nil if there is none or it has
+// exited, and every player available. Return the player to follow, which may
+// be current to keep it, or nil to follow none.
+type SelectionPolicy interface {
+	Select(current *mpris.Player, players []mpris.Player, event SelectionEvent) *mpris.Player
+}
+
+// Get a built-in policy by name: recent, sticky, priority or manual. The
+// priority list is only used by the priority policy.
+func NewSelectionPolicy(name string, priority []string) (SelectionPolicy, error) {
+	switch name {
+	case "", "recent":
+		return &RecentPolicy{}, nil
+	case "sticky":
+		return StickyPolicy{}, nil
+	case "priority":
+		return &PriorityPolicy{Names: priority}, nil
+	case "manual":
+		return ManualPolicy{}, nil
+	}
+	return nil, fmt.Errorf("Unknown selection policy %q", name)
+}
+
+// Follow whichever player most recently started playing. When it exits, go
+// back to the one that played before it.
+type RecentPolicy struct {
+	history []string // Names of players, most recently playing last
+}
+
+func (r *RecentPolicy) Select(current *mpris.Player, players []mpris.Player, event SelectionEvent) *mpris.Player {
+	if event.Reason == SelectManual || event.Reason == SelectPlayerChanged && event.status() == mpris.PlaybackPlaying {
+		r.played(event.Player.Name)
+		return event.Player
+	}
+	if current != nil {
+		return current
+	}
+
+	if player := playingPlayer(players, event); player != nil {
+		r.played(player.Name)
+		return player
+	}
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if player := findPlayer(players, r.history[i]); player != nil {
+			return player
+		}
+	}
+	return firstPlayer(players)
+}
+
+func (r *RecentPolicy) played(name string) {
+	for i, seen := range r.history {
+		if seen == name {
+			r.history = append(r.history[:i], r.history[i+1:]...)
+			break
+		}
+	}
+	r.history = append(r.history, name)
+}
+
+// Keep following the current player until it stops or exits, then move to one
+// that's playing.
+type StickyPolicy struct{}
+
+func (StickyPolicy) Select(current *mpris.Player, players []mpris.Player, event SelectionEvent) *mpris.Player {
+	if event.Reason == SelectManual {
+		return event.Player
+	}
+	if current != nil {
+		stopped := event.Reason == SelectPlayerChanged &&
+			event.Player.Name == current.Name &&
+			event.status() == mpris.PlaybackStopped
+		if !stopped {
+			return current
+		}
+	}
+	if player := playingPlayer(players, event); player != nil {
+		return player
+	}
+	if current != nil {
+		return current
+	}
+	return firstPlayer(players)
+}
+
+// Follow the available player which comes first in a fixed list of names.
+// Players not in the list are only chosen when none of the listed ones are
+// running, preferring one that's playing. A player picked by the user is
+// followed until the next player starts or exits.
+type PriorityPolicy struct {
+	Names  []string
+	manual string // Name of the player the user picked, if it's still followed
+}
+
+func (p *PriorityPolicy) Select(current *mpris.Player, players []mpris.Player, event SelectionEvent) *mpris.Player {
+	switch event.Reason {
+	case SelectManual:
+		p.manual = event.Player.Name
+		return event.Player
+	case SelectPlayerAdded, SelectPlayerRemoved:
+		p.manual = ""
+	}
+	if current != nil && current.Name == p.manual {
+		return current
+	}
+
+	for _, name := range p.Names {
+		for i := range players {
+			if players[i].Matches(name) {
+				return &players[i]
+			}
+		}
+	}
+	if player := playingPlayer(players, event); player != nil {
+		return player
+	}
+	if current != nil {
+		return current
+	}
+	return firstPlayer(players)
+}
+
+// Only change players when asked to with SetCurrentPlayer. A player is picked
+// on startup so there's something to control, but none is followed after the
+// current one exits.
+type ManualPolicy struct{}
+
+func (ManualPolicy) Select(current *mpris.Player, players []mpris.Player, event SelectionEvent) *mpris.Player {
+	if event.Reason == SelectManual {
+		return event.Player
+	}
+	if event.Reason == SelectStartup {
+		if player := playingPlayer(players, event); player != nil {
+			return player
+		}
+		return firstPlayer(players)
+	}
+	return current
+}
+
+func findPlayer(players []mpris.Player, name string) *mpris.Player {
+	for i := range players {
+		if players[i].Name == name {
+			return &players[i]
+		}
+	}
+	return nil
+}
+
+func playingPlayer(players []mpris.Player, event SelectionEvent) *mpris.Player {
+	for i := range players {
+		if event.Statuses[players[i].Name] == mpris.PlaybackPlaying {
+			return &players[i]
+		}
+	}
+	return nil
+}
+
+func firstPlayer(players []mpris.Player) *mpris.Player {
+	if len(players) == 0 {
+		return nil
+	}
+	return &players[0]
+}
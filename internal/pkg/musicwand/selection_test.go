@@ -0,0 +1,200 @@
+package musicwand
+
+import (
+	"testing"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// A selection to make, with the events the policy saw before it.
+type selectionTest struct {
+	name    string
+	before  []SelectionEvent
+	players []string // Short names of the running players, or a, b and c
+	current string
+	event   SelectionEvent
+	want    string
+}
+
+func testPlayer(name string) *mpris.Player {
+	return &mpris.Player{Name: "org.mpris.MediaPlayer2." + name}
+}
+
+// Make an event about a player, with a status change if it's not empty, and
+// the rest of the names playing.
+func testEvent(reason SelectionReason, player string, status mpris.PlaybackState, playing ...string) SelectionEvent {
+	event := SelectionEvent{Reason: reason, Statuses: make(map[string]mpris.PlaybackState)}
+	if player != "" {
+		event.Player = testPlayer(player)
+	}
+	if status != "" {
+		event.Changed = map[string]interface{}{"PlaybackStatus": status}
+	}
+	for _, name := range playing {
+		event.Statuses[testPlayer(name).Name] = mpris.PlaybackPlaying
+	}
+	return event
+}
+
+func runSelectionTests(t *testing.T, newPolicy func() SelectionPolicy, tests []selectionTest) {
+	t.Helper()
+	for _, test := range tests {
+		names := test.players
+		if names == nil {
+			names = []string{"a", "b", "c"}
+		}
+		players := make([]mpris.Player, len(names))
+		for i, name := range names {
+			players[i] = *testPlayer(name)
+		}
+		var current *mpris.Player
+		if test.current != "" {
+			current = findPlayer(players, testPlayer(test.current).Name)
+		}
+
+		policy := newPolicy()
+		for _, event := range test.before {
+			policy.Select(current, players, event)
+		}
+		got := ""
+		if player := policy.Select(current, players, test.event); player != nil {
+			got = player.ShortName()
+		}
+		if got != test.want {
+			t.Errorf("%s: selected %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRecentPolicy(t *testing.T) {
+	runSelectionTests(t, func() SelectionPolicy { return &RecentPolicy{} }, []selectionTest{
+		{name: "startup picks the first player",
+			event: testEvent(SelectStartup, "", ""), want: "a"},
+		{name: "startup picks a playing player",
+			event: testEvent(SelectStartup, "", "", "b"), want: "b"},
+		{name: "startup without players",
+			players: []string{}, event: testEvent(SelectStartup, "", ""), want: ""},
+		{name: "added player doesn't take over",
+			current: "a", event: testEvent(SelectPlayerAdded, "c", "", "c"), want: "a"},
+		{name: "added player is followed when there's none",
+			event: testEvent(SelectPlayerAdded, "c", "", "c"), want: "c"},
+		{name: "removed player falls back to the one before",
+			before: []SelectionEvent{
+				testEvent(SelectPlayerChanged, "b", mpris.PlaybackPlaying),
+				testEvent(SelectPlayerChanged, "c", mpris.PlaybackPlaying),
+			},
+			players: []string{"a", "b"}, event: testEvent(SelectPlayerRemoved, "", ""), want: "b"},
+		{name: "removed player prefers one that's playing",
+			before:  []SelectionEvent{testEvent(SelectPlayerChanged, "b", mpris.PlaybackPlaying)},
+			players: []string{"a", "b"}, event: testEvent(SelectPlayerRemoved, "", "", "a"), want: "a"},
+		{name: "player starting to play takes over",
+			current: "a", event: testEvent(SelectPlayerChanged, "b", mpris.PlaybackPlaying), want: "b"},
+		{name: "player pausing doesn't take over",
+			current: "a", event: testEvent(SelectPlayerChanged, "b", mpris.PlaybackPaused), want: "a"},
+		{name: "config change keeps the current player",
+			current: "b", event: testEvent(SelectConfigChanged, "", "", "c"), want: "b"},
+		{name: "config change without a player",
+			event: testEvent(SelectConfigChanged, "", ""), want: "a"},
+		{name: "manual pick",
+			current: "a", event: testEvent(SelectManual, "c", ""), want: "c"},
+	})
+}
+
+func TestStickyPolicy(t *testing.T) {
+	runSelectionTests(t, func() SelectionPolicy { return StickyPolicy{} }, []selectionTest{
+		{name: "startup picks the first player",
+			event: testEvent(SelectStartup, "", ""), want: "a"},
+		{name: "startup picks a playing player",
+			event: testEvent(SelectStartup, "", "", "b"), want: "b"},
+		{name: "added player doesn't take over",
+			current: "a", event: testEvent(SelectPlayerAdded, "c", "", "c"), want: "a"},
+		{name: "removed player moves to one that's playing",
+			event: testEvent(SelectPlayerRemoved, "", "", "c"), want: "c"},
+		{name: "removed player moves to the first one",
+			event: testEvent(SelectPlayerRemoved, "", ""), want: "a"},
+		{name: "other player starting to play doesn't take over",
+			current: "a", event: testEvent(SelectPlayerChanged, "b", mpris.PlaybackPlaying, "b"), want: "a"},
+		{name: "current player stopping moves to one that's playing",
+			current: "a", event: testEvent(SelectPlayerChanged, "a", mpris.PlaybackStopped, "b"), want: "b"},
+		{name: "current player stopping stays when none are playing",
+			current: "a", event: testEvent(SelectPlayerChanged, "a", mpris.PlaybackStopped), want: "a"},
+		{name: "current player pausing stays",
+			current: "a", event: testEvent(SelectPlayerChanged, "a", mpris.PlaybackPaused, "b"), want: "a"},
+		{name: "config change keeps the current player",
+			current: "b", event: testEvent(SelectConfigChanged, "", "", "c"), want: "b"},
+		{name: "manual pick",
+			current: "a", event: testEvent(SelectManual, "c", ""), want: "c"},
+	})
+}
+
+func TestPriorityPolicy(t *testing.T) {
+	priority := func(names ...string) func() SelectionPolicy {
+		return func() SelectionPolicy { return &PriorityPolicy{Names: names} }
+	}
+	manualA := []SelectionEvent{testEvent(SelectManual, "a", "")}
+
+	runSelectionTests(t, priority("c", "b"), []selectionTest{
+		{name: "startup picks the first listed player",
+			event: testEvent(SelectStartup, "", "", "a"), want: "c"},
+		{name: "startup skips listed players which aren't running",
+			players: []string{"a", "b"}, event: testEvent(SelectStartup, "", ""), want: "b"},
+		{name: "added listed player takes over",
+			current: "b", event: testEvent(SelectPlayerAdded, "c", ""), want: "c"},
+		{name: "added player ends a manual pick",
+			before: manualA, current: "a", event: testEvent(SelectPlayerAdded, "c", ""), want: "c"},
+		{name: "removed player moves to the next listed one",
+			players: []string{"a", "b"}, event: testEvent(SelectPlayerRemoved, "", "", "a"), want: "b"},
+		{name: "player change goes back to the list",
+			current: "a", event: testEvent(SelectPlayerChanged, "a", mpris.PlaybackPlaying), want: "c"},
+		{name: "player change keeps a manual pick",
+			before: manualA, current: "a", event: testEvent(SelectPlayerChanged, "b", mpris.PlaybackPlaying), want: "a"},
+		{name: "config change keeps a manual pick",
+			before: manualA, current: "a", event: testEvent(SelectConfigChanged, "", ""), want: "a"},
+		{name: "manual pick",
+			current: "c", event: testEvent(SelectManual, "a", ""), want: "a"},
+	})
+	runSelectionTests(t, priority("x*", "/^b$/"), []selectionTest{
+		{name: "patterns in the list",
+			event: testEvent(SelectStartup, "", ""), want: "b"},
+	})
+	runSelectionTests(t, priority("x"), []selectionTest{
+		{name: "unlisted players prefer one that's playing",
+			event: testEvent(SelectStartup, "", "", "c"), want: "c"},
+		{name: "unlisted players keep the current one",
+			current: "b", event: testEvent(SelectConfigChanged, "", ""), want: "b"},
+		{name: "unlisted players fall back to the first",
+			event: testEvent(SelectPlayerRemoved, "", ""), want: "a"},
+	})
+}
+
+func TestManualPolicy(t *testing.T) {
+	runSelectionTests(t, func() SelectionPolicy { return ManualPolicy{} }, []selectionTest{
+		{name: "startup picks the first player",
+			event: testEvent(SelectStartup, "", ""), want: "a"},
+		{name: "startup picks a playing player",
+			event: testEvent(SelectStartup, "", "", "b"), want: "b"},
+		{name: "added player isn't followed",
+			event: testEvent(SelectPlayerAdded, "c", "", "c"), want: ""},
+		{name: "added player doesn't take over",
+			current: "a", event: testEvent(SelectPlayerAdded, "c", "", "c"), want: "a"},
+		{name: "removed player isn't replaced",
+			event: testEvent(SelectPlayerRemoved, "", "", "b"), want: ""},
+		{name: "player starting to play doesn't take over",
+			current: "a", event: testEvent(SelectPlayerChanged, "b", mpris.PlaybackPlaying, "b"), want: "a"},
+		{name: "config change keeps the current player",
+			current: "b", event: testEvent(SelectConfigChanged, "", ""), want: "b"},
+		{name: "manual pick",
+			current: "a", event: testEvent(SelectManual, "b", ""), want: "b"},
+	})
+}
+
+func TestNewSelectionPolicy(t *testing.T) {
+	for _, name := range []string{"", "recent", "sticky", "priority", "manual"} {
+		if _, err := NewSelectionPolicy(name, nil); err != nil {
+			t.Errorf("NewSelectionPolicy(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := NewSelectionPolicy("random", nil); err == nil {
+		t.Errorf("NewSelectionPolicy(%q) succeeded, want an error", "random")
+	}
+}
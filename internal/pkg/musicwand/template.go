@@ -0,0 +1,160 @@
+package musicwand
+
+import (
+	"fmt"
+	"html"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/shreve/musicwand/pkg/mpris"
+)
+
+// What a status template can refer to. Every property of the player is
+// available, like {{.Volume}} and {{.Metadata.Album}}, along with shortcuts for
+// the ones used most.
+type StatusData struct {
+	mpris.Snapshot
+
+	Status   string // The playback status, like Playing
+	Artist   string // Every artist, joined with commas
+	Album    string
+	Title    string
+	Track    string // The same as Title, to match {track}
+	Length   int64  // In microseconds, for use with duration
+	Position int64  // Estimated from the last known position, in microseconds
+	Icon     string // The player's icon, as in {icon}
+}
+
+// Gather what a status template can refer to from the state of a player.
+func NewStatusData(state mpris.Snapshot) StatusData {
+	return StatusData{
+		Snapshot: state,
+		Status:   string(state.PlaybackStatus),
+		Artist:   strings.Join(state.Metadata.Artist, ", "),
+		Album:    state.Metadata.Album,
+		Title:    state.Metadata.Title,
+		Track:    state.Metadata.Title,
+		Length:   state.Metadata.Length,
+		Position: state.EstimatedPosition(),
+		Icon:     string(Icon(state.Identity)),
+	}
+}
+
+// Functions available to status templates.
+//
+//   duration       format microseconds like 1:23
+//   upper, lc      change to upper or lower case
+//   truncate       shorten to a number of characters, ending with …
+//   default        use a fallback for empty values
+//   emoji          show a status, volume, loop status or shuffle as an emoji
+//   markup_escape  escape text for Pango markup
+var statusFuncs = template.FuncMap{
+	"duration":      FormatTime,
+	"upper":         strings.ToUpper,
+	"lc":            strings.ToLower,
+	"truncate":      truncate,
+	"default":       fallback,
+	"emoji":         emoji,
+	"markup_escape": html.EscapeString,
+}
+
+// Templates already parsed, by their source.
+var templates = struct {
+	sync.Mutex
+	parsed map[string]*template.Template
+}{parsed: make(map[string]*template.Template)}
+
+// Check whether a status format uses the template syntax rather than plain
+// {placeholders}.
+func IsTemplate(format string) bool {
+	return strings.Contains(format, "{{")
+}
+
+// Parse a status template, reusing the result for formats seen before.
+func ParseStatusTemplate(format string) (*template.Template, error) {
+	templates.Lock()
+	defer templates.Unlock()
+	if tmpl, ok := templates.parsed[format]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := template.New("status").Funcs(statusFuncs).Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	templates.parsed[format] = tmpl
+	return tmpl, nil
+}
+
+// Fill in a status template with the state of a player.
+func RenderStatusTemplate(format string, state mpris.Snapshot) (string, error) {
+	tmpl, err := ParseStatusTemplate(format)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, NewStatusData(state)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Shorten text to a number of characters, marking the cut with an ellipsis.
+func truncate(length int, text string) string {
+	runes := []rune(text)
+	if length <= 0 || len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+// Get value, or fallback if value is empty.
+func fallback(fallback string, value interface{}) interface{} {
+	if value == nil || reflect.ValueOf(value).IsZero() {
+		return fallback
+	}
+	return value
+}
+
+// Show a playback status, volume, loop status or shuffle as an emoji.
+func emoji(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return emoji(mpris.PlaybackState(value))
+	case mpris.PlaybackState:
+		switch value {
+		case mpris.PlaybackPlaying:
+			return "▶", nil
+		case mpris.PlaybackPaused:
+			return "⏸", nil
+		case mpris.PlaybackStopped:
+			return "⏹", nil
+		}
+		return "", nil
+	case mpris.LoopState:
+		switch value {
+		case mpris.LoopTrack:
+			return "🔂", nil
+		case mpris.LoopPlaylist:
+			return "🔁", nil
+		}
+		return "", nil
+	case float64:
+		switch {
+		case value <= 0:
+			return "🔇", nil
+		case value < 0.33:
+			return "🔈", nil
+		case value < 0.66:
+			return "🔉", nil
+		}
+		return "🔊", nil
+	case bool:
+		if value {
+			return "🔀", nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("emoji can't show a %T", value)
+}
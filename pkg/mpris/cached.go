@@ -0,0 +1,307 @@
+package mpris
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Everything known about a player at one moment, covering the properties of
+// both the app and playback interfaces.
+type Snapshot struct {
+	// Properties on app: org.mpris.MediaPlayer2
+	Identity            string
+	DesktopEntry        string
+	CanQuit             bool
+	CanRaise            bool
+	CanSetFullscreen    bool
+	Fullscreen          bool
+	HasTrackList        bool
+	SupportedUriSchemes []string
+	SupportedMimeTypes  []string
+
+	// Properties on playback: org.mpris.MediaPlayer2.Player
+	PlaybackStatus PlaybackState
+	LoopStatus     LoopState
+	Rate           float64
+	Shuffle        bool
+	Volume         float64
+	Position       int64     // As of PositionTime, see PositionAt
+	PositionTime   time.Time // When Position was last known to be accurate
+	MinimumRate    float64
+	MaximumRate    float64
+	Metadata       Metadata
+	CanGoNext      bool
+	CanGoPrevious  bool
+	CanPlay        bool
+	CanPause       bool
+	CanSeek        bool
+	CanControl     bool
+}
+
+// Set a property of the snapshot from a value decoded by decodeProperty.
+// Values of the wrong type are ignored.
+func (s *Snapshot) set(name string, value interface{}) {
+	switch name {
+	case "Identity":
+		s.Identity, _ = value.(string)
+	case "DesktopEntry":
+		s.DesktopEntry, _ = value.(string)
+	case "CanQuit":
+		s.CanQuit, _ = value.(bool)
+	case "CanRaise":
+		s.CanRaise, _ = value.(bool)
+	case "CanSetFullscreen":
+		s.CanSetFullscreen, _ = value.(bool)
+	case "Fullscreen":
+		s.Fullscreen, _ = value.(bool)
+	case "HasTrackList":
+		s.HasTrackList, _ = value.(bool)
+	case "SupportedUriSchemes":
+		s.SupportedUriSchemes, _ = value.([]string)
+	case "SupportedMimeTypes":
+		s.SupportedMimeTypes, _ = value.([]string)
+	case "PlaybackStatus":
+		s.PlaybackStatus, _ = value.(PlaybackState)
+	case "LoopStatus":
+		s.LoopStatus, _ = value.(LoopState)
+	case "Rate":
+		s.Rate, _ = value.(float64)
+	case "Shuffle":
+		s.Shuffle, _ = value.(bool)
+	case "Volume":
+		s.Volume, _ = value.(float64)
+	case "Position":
+		// A new position is measured now, not when the old one was.
+		position, _ := value.(int64)
+		s.setPosition(position, time.Now())
+	case "MinimumRate":
+		s.MinimumRate, _ = value.(float64)
+	case "MaximumRate":
+		s.MaximumRate, _ = value.(float64)
+	case "Metadata":
+		s.Metadata, _ = value.(Metadata)
+	case "CanGoNext":
+		s.CanGoNext, _ = value.(bool)
+	case "CanGoPrevious":
+		s.CanGoPrevious, _ = value.(bool)
+	case "CanPlay":
+		s.CanPlay, _ = value.(bool)
+	case "CanPause":
+		s.CanPause, _ = value.(bool)
+	case "CanSeek":
+		s.CanSeek, _ = value.(bool)
+	case "CanControl":
+		s.CanControl, _ = value.(bool)
+	}
+}
+
+// Estimate the position at the given time. Players don't announce changes to
+// Position as playback advances, so this extrapolates from the last known
+// position using the playback status and rate. The result never goes past the
+// end of the track.
+func (s Snapshot) PositionAt(t time.Time) int64 {
+	position := s.Position
+	if s.PlaybackStatus == PlaybackPlaying && !s.PositionTime.IsZero() {
+		rate := s.Rate
+		if rate <= 0 {
+			rate = 1.0
+		}
+		elapsed := t.Sub(s.PositionTime)
+		position += int64(float64(elapsed.Microseconds()) * rate)
+	}
+	if s.Metadata.Length > 0 && position > s.Metadata.Length {
+		position = s.Metadata.Length
+	}
+	if position < 0 {
+		position = 0
+	}
+	return position
+}
+
+// Estimate the current position. See PositionAt.
+func (s Snapshot) EstimatedPosition() int64 {
+	return s.PositionAt(time.Now())
+}
+
+// Set the position as measured at a given time.
+func (s *Snapshot) setPosition(position int64, t time.Time) {
+	s.Position = position
+	s.PositionTime = t
+}
+
+// Set every property in a raw property map on the snapshot.
+func (s *Snapshot) setAll(props map[string]dbus.Variant) {
+	for name, variant := range props {
+		s.set(name, decodeProperty(name, variant))
+	}
+}
+
+// Load every property of the player in two calls. Returns an error if either
+// interface can't be read.
+func (p *Player) GetSnapshot() (Snapshot, error) {
+	var snapshot Snapshot
+	for _, iface := range []string{appInterface, playerInterface} {
+		var props map[string]dbus.Variant
+		err := p.call(getAllPropertyMethod, iface).Store(&props)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.setAll(props)
+	}
+	snapshot.PositionTime = time.Now()
+	return snapshot, nil
+}
+
+// A player whose properties are loaded once and then kept current from the
+// signals it emits, so reading them doesn't touch the bus. Methods of the
+// embedded Player still call the player directly; read cached values through
+// Snapshot.
+type CachedPlayer struct {
+	*Player
+
+	mu       sync.RWMutex
+	snapshot Snapshot
+	updates  chan struct{}
+	cancel   context.CancelFunc
+}
+
+// Load the state of a player and start following its changes. Call Close to
+// stop following.
+func NewCachedPlayer(player *Player) (*CachedPlayer, error) {
+	ctx, cancel := context.WithCancel(player.Context())
+	c := &CachedPlayer{
+		Player:  player,
+		updates: make(chan struct{}, 1),
+		cancel:  cancel,
+	}
+
+	// Subscribe before loading so no change is missed in between.
+	following := player.WithContext(ctx)
+	changes, err := following.OnChange()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	seeks, err := following.OnSeek()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := c.Refresh(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go c.follow(ctx, changes, seeks)
+	return c, nil
+}
+
+// Stop following changes to the player.
+func (c *CachedPlayer) Close() {
+	c.cancel()
+}
+
+// Reload every property from the player.
+func (c *CachedPlayer) Refresh() error {
+	snapshot, err := c.Player.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.snapshot = snapshot
+	c.mu.Unlock()
+	c.notify()
+	return nil
+}
+
+// Get a consistent copy of the player's current state.
+func (c *CachedPlayer) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot
+}
+
+// Get a channel which receives a value whenever the cached state changes.
+// Changes which arrive before the last one is received are coalesced.
+func (c *CachedPlayer) Updates() <-chan struct{} {
+	return c.updates
+}
+
+func (c *CachedPlayer) notify() {
+	select {
+	case c.updates <- struct{}{}:
+	default:
+	}
+}
+
+func (c *CachedPlayer) follow(ctx context.Context, changes chan PropertiesChangedEvent, seeks chan SeekedEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-changes:
+			if !ok {
+				return
+			}
+			c.applyChange(event)
+		case event, ok := <-seeks:
+			if !ok {
+				return
+			}
+			c.mu.Lock()
+			c.snapshot.setPosition(event.Position, time.Now())
+			c.mu.Unlock()
+			c.notify()
+		}
+	}
+}
+
+// Update the snapshot from a change event. Invalidated properties are fetched
+// from the player since their values weren't sent.
+func (c *CachedPlayer) applyChange(event PropertiesChangedEvent) {
+	if event.Interface != appInterface && event.Interface != playerInterface {
+		return
+	}
+
+	fetched := make(map[string]dbus.Variant)
+	for _, name := range event.Invalidated {
+		value, err := c.Player.Get(event.Interface, name)
+		if err == nil {
+			fetched[name] = value
+		}
+	}
+
+	// A new status, rate or track makes the position estimate drift, so ask
+	// the player where it is now. If it won't say, freeze the estimate where it
+	// was before the change took effect.
+	resync := false
+	if event.Interface == playerInterface {
+		for _, name := range []string{"PlaybackStatus", "Rate", "Metadata"} {
+			_, changed := event.Changed[name]
+			_, invalidated := fetched[name]
+			resync = resync || changed || invalidated
+		}
+	}
+	var position int64
+	var positionErr error
+	if resync {
+		position, positionErr = c.Player.GetPosition()
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	if resync && positionErr == nil {
+		c.snapshot.setPosition(position, now)
+	} else if resync {
+		c.snapshot.setPosition(c.snapshot.PositionAt(now), now)
+	}
+	for name, value := range event.Changed {
+		c.snapshot.set(name, value)
+	}
+	c.snapshot.setAll(fetched)
+	c.mu.Unlock()
+	c.notify()
+}
@@ -0,0 +1,36 @@
+package mpris
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotPositionAt(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := start.Add(10 * time.Second)
+	track := Metadata{Length: 60e6}
+
+	tests := []struct {
+		name  string
+		state Snapshot
+		at    time.Time
+		want  int64
+	}{
+		{"playing", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 5e6, PositionTime: start, Rate: 1, Metadata: track}, later, 15e6},
+		{"playing without a rate", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 5e6, PositionTime: start, Metadata: track}, later, 15e6},
+		{"playing fast", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 5e6, PositionTime: start, Rate: 2, Metadata: track}, later, 25e6},
+		{"playing slow", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 5e6, PositionTime: start, Rate: 0.5, Metadata: track}, later, 10e6},
+		{"paused", Snapshot{PlaybackStatus: PlaybackPaused, Position: 5e6, PositionTime: start, Rate: 1, Metadata: track}, later, 5e6},
+		{"stopped", Snapshot{PlaybackStatus: PlaybackStopped, Position: 5e6, PositionTime: start, Rate: 1, Metadata: track}, later, 5e6},
+		{"unknown position time", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 5e6, Rate: 1, Metadata: track}, later, 5e6},
+		{"past the end", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 55e6, PositionTime: start, Rate: 1, Metadata: track}, later, 60e6},
+		{"unknown length", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 55e6, PositionTime: start, Rate: 1}, later, 65e6},
+		{"before the position time", Snapshot{PlaybackStatus: PlaybackPlaying, Position: 5e6, PositionTime: later, Rate: 1, Metadata: track}, start, 0},
+		{"negative position", Snapshot{PlaybackStatus: PlaybackPaused, Position: -1, Metadata: track}, later, 0},
+	}
+	for _, test := range tests {
+		if got := test.state.PositionAt(test.at); got != test.want {
+			t.Errorf("%s: PositionAt() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
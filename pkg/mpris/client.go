@@ -1,23 +1,98 @@
 package mpris
 
 import (
+	"context"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
 
+// How long to wait for a player to respond before giving up.
+const DefaultTimeout = 5 * time.Second
+
 // Handle for finding MPRIS interfaces to interact with.
 type Client struct {
-	conn *dbus.Conn
+	// How long to wait for any single call before failing with ErrTimeout.
+	// Players found by this client inherit it. Zero means wait forever.
+	Timeout time.Duration
+
+	conn    *dbus.Conn
+	signals *dispatcher
+	ctx     context.Context
+	filters *playerFilters
+}
+
+// Which players a client leaves out or lists first. Shared by copies of the
+// client, and safe to change while it's in use.
+type playerFilters struct {
+	sync.Mutex
+	ignore   []string
+	priority []string
 }
 
-// Create a new client and connect to D-Bus.
+// Create a new client with its own connection to D-Bus, so signals reach it
+// in the order they were sent.
 func NewClient() (*Client, error) {
-	conn, err := dbus.SessionBus()
+	conn, signals, err := sessionConn()
 	if err != nil {
 		return nil, err
 	}
-	return &Client{conn}, nil
+	return &Client{
+		Timeout: DefaultTimeout,
+		conn:    conn,
+		signals: signals,
+		filters: &playerFilters{},
+	}, nil
+}
+
+// Leave players matching any of these names out of Players, as if they
+// weren't running. See Player.Matches.
+func (c *Client) SetIgnore(names []string) {
+	c.filters.Lock()
+	defer c.filters.Unlock()
+	c.filters.ignore = names
+}
+
+// List players matching these names first in Players, in this order. Players
+// of the same priority are sorted by name.
+func (c *Client) SetPriority(names []string) {
+	c.filters.Lock()
+	defer c.filters.Unlock()
+	c.filters.priority = names
+}
+
+// Get the names set with SetIgnore and SetPriority.
+func (c *Client) filterNames() (ignore, priority []string) {
+	c.filters.Lock()
+	defer c.filters.Unlock()
+	return c.filters.ignore, c.filters.priority
+}
+
+// Get a copy of this client which makes every call with the given context.
+// Players found by the copy use the same context.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	copy := *c
+	copy.ctx = ctx
+	return &copy
+}
+
+// Get the context calls by this client are made with.
+func (c *Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// Call a method on the bus itself, bounded by the client's context and timeout.
+func (c *Client) busCall(method string, args ...interface{}) *dbus.Call {
+	return callWithTimeout(c.Context(), c.Timeout, c.conn.BusObject(), method, args...)
 }
 
 // Close the D-Bus connection used by this client.
@@ -28,37 +103,82 @@ func (c *Client) Close() {
 // Get a complete list of players which use the MPRIS bus name:
 //   org.mpris.MediaPlayer2.{appName}
 func (c *Client) Players() (players []Player) {
+	ignore, priority := c.filterNames()
+	players = c.listPlayers(ignore)
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i].priority(priority), players[j].priority(priority)
+		if a != b {
+			return a < b
+		}
+		return players[i].Name < players[j].Name
+	})
+	return
+}
+
+// List the players on the bus, in no particular order, leaving out those
+// matching ignore before asking for their owners.
+func (c *Client) listPlayers(ignore []string) (players []Player) {
 	var list []string
-	err := c.conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&list)
+	err := c.busCall("org.freedesktop.DBus.ListNames").Store(&list)
 	if err != nil {
 		return
 	}
 	for _, name := range list {
 		if strings.HasPrefix(name, appInterface) {
-			object := c.conn.Object(name, objectPath).(*dbus.Object)
+			player := c.newPlayer(name, "")
+			if player.matchesAny(ignore) {
+				continue
+			}
+			c.busCall("org.freedesktop.DBus.GetNameOwner", name).Store(&player.Owner)
 
-			var owner string
-			c.conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner)
-
-			players = append(players, Player{
-				conn:  c.conn,
-				obj:   object,
-				Name:  name,
-				Owner: owner,
-			})
+			players = append(players, player)
 		}
 	}
 	return
 }
 
-// Find a player based on it's registered name. This will match any suffix.
+// Create a handle for the player with the given bus name and owner.
+func (c *Client) newPlayer(name, owner string) Player {
+	return Player{
+		conn:    c.conn,
+		obj:     c.conn.Object(name, objectPath).(*dbus.Object),
+		signals: c.signals,
+		ctx:     c.ctx,
+		timeout: c.Timeout,
+		Name:    name,
+		Owner:   owner,
+	}
+}
+
+// Find a player based on it's registered name. This will match any suffix,
+// anything Player.Matches accepts, or the player's Identity, which may also be
+// a glob or regular expression.
 func (c *Client) FindPlayer(name string) *Player {
-	for _, player := range c.Players() {
-		if strings.HasSuffix(player.Name, name) {
-			return &player
+	players := c.FindPlayers(name)
+	if len(players) == 0 {
+		return nil
+	}
+	return &players[0]
+}
+
+// Find every player FindPlayer would accept, in the order Players lists them.
+func (c *Client) FindPlayers(name string) []Player {
+	return FilterPlayers(c.Players(), name)
+}
+
+// Get the players in a list which FindPlayer would accept for a name.
+func FilterPlayers(players []Player, name string) (found []Player) {
+	for _, player := range players {
+		if strings.HasSuffix(player.Name, name) || player.Matches(name) {
+			found = append(found, player)
+			continue
+		}
+		identity, err := player.GetIdentity()
+		if err == nil && (strings.EqualFold(identity, name) || matchPattern(name, identity)) {
+			found = append(found, player)
 		}
 	}
-	return nil
+	return
 }
 
 // Find a player based on the unique name of the owner. This is useful for
@@ -72,16 +192,76 @@ func (c *Client) PlayerWithOwner(owner string) *Player {
 	return nil
 }
 
-// Get a channel of signals that any player has changed a property.
-func (c *Client) OnAnyPlayerChange() (chan *dbus.Signal, error) {
-	signals := make(chan *dbus.Signal, 10)
-	err := c.conn.AddMatchSignal(
-		dbus.WithMatchObjectPath(objectPath),
-		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
-	)
+// Subscribe to signals on the bus which satisfy the given rule. Call
+// Unsubscribe on the result when finished.
+func (c *Client) Subscribe(rule MatchRule) (*Subscription, error) {
+	return c.signals.subscribe(rule)
+}
+
+// Get a channel of events that any player has changed a property, leaving out
+// ignored players. The channel is closed when the client's context is done.
+func (c *Client) OnAnyPlayerChange() (chan PropertiesChangedEvent, error) {
+	events := make(chan PropertiesChangedEvent, 10)
+	ctx, cancel := context.WithCancel(c.Context())
+	rule := MatchRule{
+		Path:      objectPath,
+		Interface: propertyInterface,
+		Member:    propertiesChangedMember,
+	}
+
+	// Players by owner. Unique names are never reused, so entries are only
+	// dropped when a player's name changes hands. Senders which aren't players
+	// yet are looked up again each time, as they may take a name later.
+	var mu sync.Mutex
+	owners := make(map[string]*Player)
+	lookup := func(sender string) *Player {
+		mu.Lock()
+		defer mu.Unlock()
+		if player, ok := owners[sender]; ok {
+			return player
+		}
+		for _, player := range c.listPlayers(nil) {
+			player := player
+			owners[player.Owner] = &player
+		}
+		return owners[sender]
+	}
+	err := c.signals.forward(ctx, playerOwnersRule, func(signal *dbus.Signal) {
+		event, ok := c.parseNameOwnerChanged(signal)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		delete(owners, event.OldOwner)
+		if event.Player != nil {
+			owners[event.NewOwner] = event.Player
+		}
+	}, func() {})
 	if err != nil {
-		return signals, err
+		cancel()
+		close(events)
+		return events, err
 	}
-	c.conn.Signal(signals)
-	return signals, nil
+
+	err = c.signals.forward(ctx, rule, func(signal *dbus.Signal) {
+		found := lookup(signal.Sender)
+		if found == nil {
+			return
+		}
+		if ignore, _ := c.filterNames(); found.matchesAny(ignore) {
+			return
+		}
+		player := *found
+		if event, ok := parsePropertiesChanged(&player, signal); ok {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() {
+		cancel()
+		close(events)
+	})
+	return events, err
 }
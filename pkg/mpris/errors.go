@@ -0,0 +1,73 @@
+package mpris
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Errors returned by calls to a player. They are wrapped with more
+// detail, so compare using errors.Is.
+var (
+	// The player does not implement the requested property or interface.
+	ErrNotSupported = errors.New("not supported by player")
+
+	// The player has exited or the connection to it has been closed.
+	ErrPlayerGone = errors.New("player is gone")
+
+	// The player did not respond before the deadline.
+	ErrTimeout = errors.New("player did not respond in time")
+
+	// The player returned a value of a different type than the spec requires.
+	ErrWrongType = errors.New("unexpected type")
+
+	// The value given to a setter isn't one the spec allows.
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// Returned by Server.Serve when another process takes over the server's bus
+// name.
+var ErrNameLost = errors.New("bus name was taken by another process")
+
+// Translate an error from a D-Bus call into one of the sentinel errors where
+// possible, keeping the original message for context.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	if errors.Is(err, dbus.ErrClosed) {
+		return fmt.Errorf("%w: %v", ErrPlayerGone, err)
+	}
+
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		switch dbusErr.Name {
+		case "org.freedesktop.DBus.Error.UnknownProperty",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.InvalidArgs",
+			"org.freedesktop.DBus.Error.NotSupported",
+			"org.freedesktop.DBus.Error.PropertyReadOnly":
+			return fmt.Errorf("%w: %v", ErrNotSupported, err)
+		case "org.freedesktop.DBus.Error.ServiceUnknown",
+			"org.freedesktop.DBus.Error.NameHasNoOwner",
+			"org.freedesktop.DBus.Error.UnknownObject",
+			"org.freedesktop.DBus.Error.Disconnected":
+			return fmt.Errorf("%w: %v", ErrPlayerGone, err)
+		case "org.freedesktop.DBus.Error.NoReply",
+			"org.freedesktop.DBus.Error.Timeout":
+			return fmt.Errorf("%w: %v", ErrTimeout, err)
+		}
+	}
+	return err
+}
+
+// Build an error for a property value of the wrong type.
+func wrongType(iface, prop string, value interface{}) error {
+	return fmt.Errorf("%w: %s.%s is %T", ErrWrongType, iface, prop, value)
+}
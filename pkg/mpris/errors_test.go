@@ -0,0 +1,54 @@
+package mpris
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestClassifyError(t *testing.T) {
+	dbusError := func(name string) error {
+		return dbus.Error{Name: name, Body: []interface{}{"message"}}
+	}
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{context.DeadlineExceeded, ErrTimeout},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), ErrTimeout},
+		{dbus.ErrClosed, ErrPlayerGone},
+		{dbusError("org.freedesktop.DBus.Error.UnknownProperty"), ErrNotSupported},
+		{dbusError("org.freedesktop.DBus.Error.UnknownInterface"), ErrNotSupported},
+		{dbusError("org.freedesktop.DBus.Error.UnknownMethod"), ErrNotSupported},
+		{dbusError("org.freedesktop.DBus.Error.InvalidArgs"), ErrNotSupported},
+		{dbusError("org.freedesktop.DBus.Error.NotSupported"), ErrNotSupported},
+		{dbusError("org.freedesktop.DBus.Error.PropertyReadOnly"), ErrNotSupported},
+		{dbusError("org.freedesktop.DBus.Error.ServiceUnknown"), ErrPlayerGone},
+		{dbusError("org.freedesktop.DBus.Error.NameHasNoOwner"), ErrPlayerGone},
+		{dbusError("org.freedesktop.DBus.Error.UnknownObject"), ErrPlayerGone},
+		{dbusError("org.freedesktop.DBus.Error.Disconnected"), ErrPlayerGone},
+		{dbusError("org.freedesktop.DBus.Error.NoReply"), ErrTimeout},
+		{dbusError("org.freedesktop.DBus.Error.Timeout"), ErrTimeout},
+		{dbusError("org.mpris.MediaPlayer2.Error.Failed"), nil},
+		{errors.New("something else"), nil},
+	}
+	for _, test := range tests {
+		got := classifyError(test.err)
+		switch {
+		case test.err == nil:
+			if got != nil {
+				t.Errorf("classifyError(nil) = %v, want nil", got)
+			}
+		case test.want == nil:
+			if got == nil || got.Error() != test.err.Error() {
+				t.Errorf("classifyError(%v) = %v, want it unchanged", test.err, got)
+			}
+		case !errors.Is(got, test.want):
+			t.Errorf("classifyError(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
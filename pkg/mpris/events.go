@@ -0,0 +1,90 @@
+package mpris
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	propertiesChangedMember = "PropertiesChanged"
+	seekedMember            = "Seeked"
+)
+
+// Some properties of a player have changed.
+type PropertiesChangedEvent struct {
+	Player    *Player
+	Interface string // The interface the properties belong to
+
+	// The new values, unwrapped from their variants. Metadata is decoded into a
+	// Metadata struct, PlaybackStatus into a PlaybackState and LoopStatus into a
+	// LoopState.
+	Changed map[string]interface{}
+
+	// Properties which changed but whose new values were not sent.
+	Invalidated []string
+}
+
+// The player has jumped to a new position in the current track.
+type SeekedEvent struct {
+	Player   *Player
+	Position int64 // The new position, in microseconds
+}
+
+// Decode a PropertiesChanged signal. Returns false if the body is malformed.
+func parsePropertiesChanged(player *Player, signal *dbus.Signal) (PropertiesChangedEvent, bool) {
+	if len(signal.Body) != 3 {
+		return PropertiesChangedEvent{}, false
+	}
+	iface, ok := signal.Body[0].(string)
+	if !ok {
+		return PropertiesChangedEvent{}, false
+	}
+	raw, ok := signal.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return PropertiesChangedEvent{}, false
+	}
+	invalidated, _ := signal.Body[2].([]string)
+
+	changed := make(map[string]interface{}, len(raw))
+	for key, variant := range raw {
+		changed[key] = decodeProperty(key, variant)
+	}
+	return PropertiesChangedEvent{
+		Player:      player,
+		Interface:   iface,
+		Changed:     changed,
+		Invalidated: invalidated,
+	}, true
+}
+
+// Unwrap a property value, converting it to this package's types where one
+// exists.
+func decodeProperty(name string, variant dbus.Variant) interface{} {
+	value := variant.Value()
+	switch name {
+	case "Metadata":
+		if raw, ok := value.(map[string]dbus.Variant); ok {
+			return ParseMetadata(raw)
+		}
+	case "PlaybackStatus":
+		if status, ok := value.(string); ok {
+			return PlaybackState(status)
+		}
+	case "LoopStatus":
+		if status, ok := value.(string); ok {
+			return LoopState(status)
+		}
+	}
+	return value
+}
+
+// Decode a Seeked signal. Returns false if the body is malformed.
+func parseSeeked(player *Player, signal *dbus.Signal) (SeekedEvent, bool) {
+	if len(signal.Body) != 1 {
+		return SeekedEvent{}, false
+	}
+	position, ok := signal.Body[0].(int64)
+	if !ok {
+		return SeekedEvent{}, false
+	}
+	return SeekedEvent{Player: player, Position: position}, true
+}
@@ -0,0 +1,237 @@
+package mpris
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Information about a media item, as described by the MPRIS metadata spec.
+// Players are not always well-behaved about the types they send, so each field
+// is decoded as leniently as possible. Keys not covered here end up in Extra.
+type Metadata struct {
+	TrackID string // mpris:trackid
+	Length  int64  // mpris:length, in microseconds
+	ArtURL  string // mpris:artUrl
+
+	Album          string   // xesam:album
+	AlbumArtist    []string // xesam:albumArtist
+	Artist         []string // xesam:artist
+	AsText         string   // xesam:asText, usually the lyrics
+	AudioBPM       int      // xesam:audioBPM
+	AutoRating     float64  // xesam:autoRating, from 0.0 to 1.0
+	Comment        []string // xesam:comment
+	Composer       []string // xesam:composer
+	ContentCreated string   // xesam:contentCreated, an ISO 8601 date
+	DiscNumber     int      // xesam:discNumber
+	FirstUsed      string   // xesam:firstUsed, an ISO 8601 date
+	Genre          []string // xesam:genre
+	LastUsed       string   // xesam:lastUsed, an ISO 8601 date
+	Lyricist       []string // xesam:lyricist
+	Title          string   // xesam:title
+	TrackNumber    int      // xesam:trackNumber
+	URL            string   // xesam:url
+	UseCount       int      // xesam:useCount
+	UserRating     float64  // xesam:userRating, from 0.0 to 1.0
+
+	Extra map[string]dbus.Variant // Any other keys, such as vendor extensions
+}
+
+// Decode a raw metadata map into a Metadata struct. Values of an unexpected
+// type are converted where it makes sense and dropped otherwise.
+func ParseMetadata(raw map[string]dbus.Variant) Metadata {
+	meta := Metadata{Extra: make(map[string]dbus.Variant)}
+	for key, variant := range raw {
+		value := variant.Value()
+		switch key {
+		case "mpris:trackid":
+			meta.TrackID = metaString(value)
+		case "mpris:length":
+			meta.Length = metaInt(value)
+		case "mpris:artUrl":
+			meta.ArtURL = metaString(value)
+		case "xesam:album":
+			meta.Album = metaString(value)
+		case "xesam:albumArtist":
+			meta.AlbumArtist = metaStringList(value)
+		case "xesam:artist":
+			meta.Artist = metaStringList(value)
+		case "xesam:asText":
+			meta.AsText = metaString(value)
+		case "xesam:audioBPM":
+			meta.AudioBPM = int(metaInt(value))
+		case "xesam:autoRating":
+			meta.AutoRating = metaFloat(value)
+		case "xesam:comment":
+			meta.Comment = metaStringList(value)
+		case "xesam:composer":
+			meta.Composer = metaStringList(value)
+		case "xesam:contentCreated":
+			meta.ContentCreated = metaString(value)
+		case "xesam:discNumber":
+			meta.DiscNumber = int(metaInt(value))
+		case "xesam:firstUsed":
+			meta.FirstUsed = metaString(value)
+		case "xesam:genre":
+			meta.Genre = metaStringList(value)
+		case "xesam:lastUsed":
+			meta.LastUsed = metaString(value)
+		case "xesam:lyricist":
+			meta.Lyricist = metaStringList(value)
+		case "xesam:title":
+			meta.Title = metaString(value)
+		case "xesam:trackNumber":
+			meta.TrackNumber = int(metaInt(value))
+		case "xesam:url":
+			meta.URL = metaString(value)
+		case "xesam:useCount":
+			meta.UseCount = int(metaInt(value))
+		case "xesam:userRating":
+			meta.UserRating = metaFloat(value)
+		default:
+			meta.Extra[key] = variant
+		}
+	}
+	return meta
+}
+
+// Encode the metadata into the map sent over D-Bus, using the types the spec
+// requires. Empty fields are left out.
+func (m Metadata) Map() map[string]dbus.Variant {
+	raw := make(map[string]dbus.Variant, len(m.Extra))
+	for key, value := range m.Extra {
+		raw[key] = value
+	}
+
+	putString := func(key, value string) {
+		if value != "" {
+			raw[key] = dbus.MakeVariant(value)
+		}
+	}
+	putList := func(key string, value []string) {
+		if len(value) > 0 {
+			raw[key] = dbus.MakeVariant(value)
+		}
+	}
+	putInt := func(key string, value int) {
+		if value != 0 {
+			raw[key] = dbus.MakeVariant(int32(value))
+		}
+	}
+	putFloat := func(key string, value float64) {
+		if value != 0 {
+			raw[key] = dbus.MakeVariant(value)
+		}
+	}
+
+	if m.TrackID != "" {
+		raw["mpris:trackid"] = dbus.MakeVariant(dbus.ObjectPath(m.TrackID))
+	}
+	if m.Length != 0 {
+		raw["mpris:length"] = dbus.MakeVariant(m.Length)
+	}
+	putString("mpris:artUrl", m.ArtURL)
+	putString("xesam:album", m.Album)
+	putList("xesam:albumArtist", m.AlbumArtist)
+	putList("xesam:artist", m.Artist)
+	putString("xesam:asText", m.AsText)
+	putInt("xesam:audioBPM", m.AudioBPM)
+	putFloat("xesam:autoRating", m.AutoRating)
+	putList("xesam:comment", m.Comment)
+	putList("xesam:composer", m.Composer)
+	putString("xesam:contentCreated", m.ContentCreated)
+	putInt("xesam:discNumber", m.DiscNumber)
+	putString("xesam:firstUsed", m.FirstUsed)
+	putList("xesam:genre", m.Genre)
+	putString("xesam:lastUsed", m.LastUsed)
+	putList("xesam:lyricist", m.Lyricist)
+	putString("xesam:title", m.Title)
+	putInt("xesam:trackNumber", m.TrackNumber)
+	putString("xesam:url", m.URL)
+	putInt("xesam:useCount", m.UseCount)
+	putFloat("xesam:userRating", m.UserRating)
+	return raw
+}
+
+// Coerce a metadata value into a string. Lists are joined with commas.
+func metaString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case dbus.ObjectPath:
+		return string(v)
+	case []string:
+		return strings.Join(v, ", ")
+	case []interface{}:
+		return strings.Join(metaStringList(v), ", ")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Coerce a metadata value into a list of strings. Empty strings are dropped.
+func metaStringList(value interface{}) []string {
+	var list []string
+	switch v := value.(type) {
+	case []string:
+		list = v
+	case []interface{}:
+		for _, item := range v {
+			list = append(list, metaString(item))
+		}
+	default:
+		list = []string{metaString(v)}
+	}
+
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Coerce a metadata value into an integer. Returns 0 if not possible.
+func metaInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case byte:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case string:
+		i, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return i
+	}
+	return 0
+}
+
+// Coerce a metadata value into a float. Returns 0 if not possible.
+func metaFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f
+	}
+	return float64(metaInt(value))
+}
@@ -0,0 +1,83 @@
+package mpris
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  map[string]interface{}
+		want Metadata
+	}{
+		{"empty", nil, Metadata{}},
+		{"spec types", map[string]interface{}{
+			"mpris:trackid":     dbus.ObjectPath("/org/mpris/MediaPlayer2/Track/1"),
+			"mpris:length":      int64(200000000),
+			"xesam:title":       "Title",
+			"xesam:artist":      []string{"One", "Two"},
+			"xesam:trackNumber": int32(3),
+			"xesam:userRating":  0.5,
+		}, Metadata{
+			TrackID:     "/org/mpris/MediaPlayer2/Track/1",
+			Length:      200000000,
+			Title:       "Title",
+			Artist:      []string{"One", "Two"},
+			TrackNumber: 3,
+			UserRating:  0.5,
+		}},
+		{"wrong types", map[string]interface{}{
+			"mpris:trackid":     "/track/1",
+			"mpris:length":      uint64(5000000),
+			"xesam:title":       []string{"Part", "Two"},
+			"xesam:artist":      "Solo",
+			"xesam:album":       int32(1999),
+			"xesam:trackNumber": " 7 ",
+			"xesam:discNumber":  2.0,
+			"xesam:userRating":  "0.25",
+			"xesam:autoRating":  int32(1),
+			"xesam:genre":       []interface{}{"Rock", "", "Pop"},
+		}, Metadata{
+			TrackID:     "/track/1",
+			Length:      5000000,
+			Title:       "Part, Two",
+			Artist:      []string{"Solo"},
+			Album:       "1999",
+			TrackNumber: 7,
+			DiscNumber:  2,
+			UserRating:  0.25,
+			AutoRating:  1,
+			Genre:       []string{"Rock", "Pop"},
+		}},
+		{"unusable values", map[string]interface{}{
+			"mpris:length":     "long",
+			"xesam:useCount":   []string{"1"},
+			"xesam:userRating": true,
+			"xesam:artist":     "",
+		}, Metadata{Artist: []string{}}},
+	}
+	for _, test := range tests {
+		raw := make(map[string]dbus.Variant, len(test.raw))
+		for key, value := range test.raw {
+			raw[key] = dbus.MakeVariant(value)
+		}
+		test.want.Extra = make(map[string]dbus.Variant)
+		if got := ParseMetadata(raw); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ParseMetadata() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseMetadataExtra(t *testing.T) {
+	raw := map[string]dbus.Variant{
+		"xesam:title":   dbus.MakeVariant("Title"),
+		"vendor:rating": dbus.MakeVariant(int32(4)),
+	}
+	meta := ParseMetadata(raw)
+	if len(meta.Extra) != 1 || meta.Extra["vendor:rating"].Value() != int32(4) {
+		t.Errorf("ParseMetadata() kept extra keys %v, want only vendor:rating", meta.Extra)
+	}
+}
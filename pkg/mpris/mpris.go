@@ -2,15 +2,23 @@
 package mpris
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
 
 const (
-	objectPath      = "/org/mpris/MediaPlayer2"
-	appInterface    = "org.mpris.MediaPlayer2"
-	playerInterface = "org.mpris.MediaPlayer2.Player"
+	objectPath         = "/org/mpris/MediaPlayer2"
+	appInterface       = "org.mpris.MediaPlayer2"
+	playerInterface    = "org.mpris.MediaPlayer2.Player"
+	trackListInterface = "org.mpris.MediaPlayer2.TrackList"
+	playlistsInterface = "org.mpris.MediaPlayer2.Playlists"
 )
 
 // Convert an error into a dbus failed error if the error exists.
@@ -22,60 +30,135 @@ func DbusError(err error) *dbus.Error {
 	}
 }
 
-func getProp(obj *dbus.Object, iface, prop string) (result dbus.Variant, err error) {
-	err = obj.Call(getPropertyMethod, 0, iface, prop).Store(&result)
+// Call a method on a D-Bus object. If the context has no deadline, the call is
+// cancelled after the given timeout. A timeout of zero means wait forever.
+func callWithTimeout(ctx context.Context, timeout time.Duration, obj dbus.BusObject, method string, args ...interface{}) *dbus.Call {
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	call := obj.CallWithContext(ctx, method, 0, args...)
+	call.Err = classifyError(call.Err)
+	if errors.Is(call.Err, ErrTimeout) {
+		call.Err = fmt.Errorf("%s %s: %w", obj.Destination(), method, call.Err)
+	}
+	return call
+}
+
+func getProp(p *Player, iface, prop string) (result dbus.Variant, err error) {
+	err = p.call(getPropertyMethod, iface, prop).Store(&result)
+	return result, err
+}
+
+func getBool(p *Player, iface, prop string) (bool, error) {
+	result, err := getProp(p, iface, prop)
 	if err != nil {
-		log.Println("Error getting property: ", err)
+		return false, err
+	}
+	value, ok := result.Value().(bool)
+	if !ok {
+		return false, wrongType(iface, prop, result.Value())
 	}
-	return
+	return value, nil
 }
 
-func getBool(obj *dbus.Object, iface, prop string) bool {
-	result, err := getProp(obj, iface, prop)
+func getDouble(p *Player, iface, prop string) (float64, error) {
+	result, err := getProp(p, iface, prop)
 	if err != nil {
-		return false
-	} else {
-		return result.Value().(bool)
+		return 0.0, err
+	}
+	value, ok := result.Value().(float64)
+	if !ok {
+		return 0.0, wrongType(iface, prop, result.Value())
 	}
+	return value, nil
 }
 
-func getDouble(obj *dbus.Object, iface, prop string) float64 {
-	result, err := getProp(obj, iface, prop)
+func getInt(p *Player, iface, prop string) (int64, error) {
+	result, err := getProp(p, iface, prop)
 	if err != nil {
-		return 0.0
-	} else {
-		return result.Value().(float64)
+		return 0, err
+	}
+	value, ok := result.Value().(int64)
+	if !ok {
+		return 0, wrongType(iface, prop, result.Value())
 	}
+	return value, nil
 }
 
-func getInt(obj *dbus.Object, iface, prop string) int64 {
-	result, err := getProp(obj, iface, prop)
+func getUint(p *Player, iface, prop string) (uint32, error) {
+	result, err := getProp(p, iface, prop)
 	if err != nil {
-		return 0
-	} else {
-		return result.Value().(int64)
+		return 0, err
+	}
+	value, ok := result.Value().(uint32)
+	if !ok {
+		return 0, wrongType(iface, prop, result.Value())
 	}
+	return value, nil
 }
 
-func getString(obj *dbus.Object, iface, prop string) string {
-	result, err := getProp(obj, iface, prop)
+func getString(p *Player, iface, prop string) (string, error) {
+	result, err := getProp(p, iface, prop)
 	if err != nil {
-		return ""
-	} else {
-		return result.Value().(string)
+		return "", err
 	}
+	value, ok := result.Value().(string)
+	if !ok {
+		return "", wrongType(iface, prop, result.Value())
+	}
+	return value, nil
 }
 
-func getStringList(obj *dbus.Object, iface, prop string) []string {
-	result, err := getProp(obj, iface, prop)
+func getStringList(p *Player, iface, prop string) ([]string, error) {
+	result, err := getProp(p, iface, prop)
 	if err != nil {
-		return []string{}
-	} else {
-		return result.Value().([]string)
+		return []string{}, err
+	}
+	value, ok := result.Value().([]string)
+	if !ok {
+		return []string{}, wrongType(iface, prop, result.Value())
+	}
+	return value, nil
+}
+
+func getObjectPathList(p *Player, iface, prop string) ([]string, error) {
+	result, err := getProp(p, iface, prop)
+	if err != nil {
+		return []string{}, err
 	}
+	paths, ok := result.Value().([]dbus.ObjectPath)
+	if !ok {
+		return []string{}, wrongType(iface, prop, result.Value())
+	}
+	list := make([]string, len(paths))
+	for i, path := range paths {
+		list[i] = string(path)
+	}
+	return list, nil
 }
 
-func setProp(obj *dbus.Object, iface, prop string, value interface{}) error {
-	call := obj.Call(setPropertyMethod, 0, iface, prop, value)
+func setProp(p *Player, iface, prop string, value interface{}) error {
+	variant, ok := value.(dbus.Variant)
+	if !ok {
+		variant = dbus.MakeVariant(value)
+	}
+	call := p.call(setPropertyMethod, iface, prop, variant)
 	return call.Err
 }
+
+// Check a value against a glob like spot* or a regular expression between
+// slashes like /^spot/. Anything else never matches, so plain names should be
+// compared separately. Invalid patterns never match.
+func matchPattern(pattern, value string) bool {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err == nil && re.MatchString(value)
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, err := path.Match(pattern, value)
+		return err == nil && matched
+	}
+	return false
+}
@@ -1,7 +1,11 @@
 package mpris
 
 import (
-	"log"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
@@ -10,10 +14,13 @@ import (
 // A handle for any application with playback state. This contains methods for
 // all supported MPRIS interfaces.
 type Player struct {
-	Name  string // The bus name of the player
-	Owner string // The unique connection identifier
-	conn  *dbus.Conn
-	obj   *dbus.Object
+	Name    string // The bus name of the player
+	Owner   string // The unique connection identifier
+	conn    *dbus.Conn
+	obj     *dbus.Object
+	signals *dispatcher
+	ctx     context.Context
+	timeout time.Duration
 }
 
 // State of a player, implemented as a type to act like an enum.
@@ -24,18 +31,111 @@ type LoopState string
 
 const (
 	PlaybackPlaying     PlaybackState = "Playing"
-	PlaybackPaused                    = "Paused"
-	PlaybackStopped                   = "Stopped"
-	PlaybackUnsupported               = ""
+	PlaybackPaused      PlaybackState = "Paused"
+	PlaybackStopped     PlaybackState = "Stopped"
+	PlaybackUnsupported PlaybackState = ""
 
 	LoopNone     LoopState = "None"
-	LoopTrack              = "Track"
-	LoopPlaylist           = "Playlist"
+	LoopTrack    LoopState = "Track"
+	LoopPlaylist LoopState = "Playlist"
 )
 
+// Check whether the loop status is one of the values in the spec.
+func (l LoopState) Valid() bool {
+	switch l {
+	case LoopNone, LoopTrack, LoopPlaylist:
+		return true
+	}
+	return false
+}
+
+// Get the loop status after this one, going None, Track, Playlist and back to
+// None. Unknown values start over at None.
+func (l LoopState) Next() LoopState {
+	switch l {
+	case LoopNone:
+		return LoopTrack
+	case LoopTrack:
+		return LoopPlaylist
+	}
+	return LoopNone
+}
+
+// Get the part of the bus name after org.mpris.MediaPlayer2, like spotify or
+// vlc.instance1234.
+func (p *Player) ShortName() string {
+	return strings.TrimPrefix(p.Name, appInterface+".")
+}
+
+// Check if the player goes by a name. This is either its full bus name, its
+// short name, or the short name without the instance, so vlc matches
+// org.mpris.MediaPlayer2.vlc.instance1234. The name may also be a glob like
+// chrom* or a regular expression between slashes like /^vlc/.
+func (p *Player) Matches(name string) bool {
+	short := p.ShortName()
+	if p.Name == name || short == name || strings.HasPrefix(short, name+".") {
+		return true
+	}
+	return matchPattern(name, p.Name) || matchPattern(name, short)
+}
+
+func (p *Player) matchesAny(names []string) bool {
+	for _, name := range names {
+		if p.Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get where the player falls in a priority list. Players not in the list come
+// after all those that are.
+func (p *Player) priority(names []string) int {
+	for i, name := range names {
+		if p.Matches(name) {
+			return i
+		}
+	}
+	return len(names)
+}
+
+// Get a copy of this player which makes every call with the given context.
+// The client's timeout still applies if the context has no deadline.
+func (p *Player) WithContext(ctx context.Context) *Player {
+	if ctx == nil {
+		panic("nil context")
+	}
+	copy := *p
+	copy.ctx = ctx
+	return &copy
+}
+
+// Get the context calls on this player are made with.
+func (p *Player) Context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+// Call a method on the player object, bounded by the player's context and
+// timeout. Errors are translated into the package's sentinel errors.
+func (p *Player) call(method string, args ...interface{}) *dbus.Call {
+	return callWithTimeout(p.Context(), p.timeout, p.obj, method, args...)
+}
+
 // Get a parsed introspection of the player
 func (p *Player) Introspect() (*introspect.Node, error) {
-	return introspect.Call(p.obj)
+	var data string
+	err := p.call(introspectMethod).Store(&data)
+	if err != nil {
+		return nil, err
+	}
+	var node introspect.Node
+	if err := xml.NewDecoder(strings.NewReader(data)).Decode(&node); err != nil {
+		return nil, err
+	}
+	return &node, nil
 }
 
 //
@@ -46,69 +146,69 @@ func (p *Player) Introspect() (*introspect.Node, error) {
 
 // Bring the player application to the front.
 func (p *Player) Raise() error {
-	call := p.obj.Call(appInterface+".Raise", 0)
+	call := p.call(appInterface + ".Raise")
 	return call.Err
 }
 
 // Stop the player application.
 func (p *Player) Quit() error {
-	call := p.obj.Call(appInterface+".Quit", 0)
+	call := p.call(appInterface + ".Quit")
 	return call.Err
 }
 
 // Methods on playback: org.mpris.MediaPlayer2.Player
 // Tell the player to play. This is not a toggle, only play if not playing.
 func (p *Player) Play() error {
-	call := p.obj.Call(playerInterface+".Play", 0)
+	call := p.call(playerInterface + ".Play")
 	return call.Err
 }
 
 // Tell the player to pause. This is not a toggle, only pause if not paused.
 func (p *Player) Pause() error {
-	call := p.obj.Call(playerInterface+".Pause", 0)
+	call := p.call(playerInterface + ".Pause")
 	return call.Err
 }
 
 // Tell the player to toggle playback state. Play if paused and pause if playing.
 func (p *Player) PlayPause() error {
-	call := p.obj.Call(playerInterface+".PlayPause", 0)
+	call := p.call(playerInterface + ".PlayPause")
 	return call.Err
 }
 
 // Play the next track in the list.
 func (p *Player) Next() error {
-	call := p.obj.Call(playerInterface+".Next", 0)
+	call := p.call(playerInterface + ".Next")
 	return call.Err
 }
 
 // Play the previous track in the list.
 func (p *Player) Previous() error {
-	call := p.obj.Call(playerInterface+".Previous", 0)
+	call := p.call(playerInterface + ".Previous")
 	return call.Err
 }
 
 // Stop playback.
 func (p *Player) Stop() error {
-	call := p.obj.Call(playerInterface+".Stop", 0)
+	call := p.call(playerInterface + ".Stop")
 	return call.Err
 }
 
 // Open a source at the given URI. Could be stream or file depending on player support.
 func (p *Player) OpenUri(uri string) error {
-	call := p.obj.Call(playerInterface+".OpenUri", 0, uri)
+	call := p.call(playerInterface+".OpenUri", uri)
 	return call.Err
 }
 
 // Move a delta amount of time in the track. If the value is negative, it will seek backward.
 func (p *Player) Seek(delta int64) error {
-	call := p.obj.Call(playerInterface+".Seek", 0, delta)
+	call := p.call(playerInterface+".Seek", delta)
 	return call.Err
 }
 
 // Go to a fixed position in a given track.
 func (p *Player) SetPosition(trackId string, position int64) error {
 	path := dbus.ObjectPath(trackId)
-	call := p.obj.Call(playerInterface+".SetPosition", 0, &path, position)
+	call := p.call(playerInterface+".SetPosition", &path, position)
 	return call.Err
 }
 
@@ -118,162 +218,391 @@ func (p *Player) SetPosition(trackId string, position int64) error {
 
 // Properties on app: org.mpris.MediaPlayer2
 
+// Get the value of Identity. Returns an error if it can't be read.
+func (p *Player) GetIdentity() (string, error) {
+	return getString(p, appInterface, "Identity")
+}
+
 func (p *Player) Identity() string {
-	return getString(p.obj, appInterface, "Identity")
+	value, _ := p.GetIdentity()
+	return value
+}
+
+// Get the value of DesktopEntry. Returns an error if it can't be read.
+func (p *Player) GetDesktopEntry() (string, error) {
+	return getString(p, appInterface, "DesktopEntry")
 }
 
 func (p *Player) DesktopEntry() string {
-	return getString(p.obj, appInterface, "DesktopEntry")
+	value, _ := p.GetDesktopEntry()
+	return value
+}
+
+// Get the value of CanRaise. Returns an error if it can't be read.
+func (p *Player) GetCanRaise() (bool, error) {
+	return getBool(p, appInterface, "CanRaise")
 }
 
 func (p *Player) CanRaise() bool {
-	return getBool(p.obj, appInterface, "CanRaise")
+	value, _ := p.GetCanRaise()
+	return value
+}
+
+// Get the value of CanQuit. Returns an error if it can't be read.
+func (p *Player) GetCanQuit() (bool, error) {
+	return getBool(p, appInterface, "CanQuit")
 }
 
 func (p *Player) CanQuit() bool {
-	return getBool(p.obj, appInterface, "CanQuit")
+	value, _ := p.GetCanQuit()
+	return value
+}
+
+// Get the value of CanSetFullscreen. Returns an error if it can't be read.
+func (p *Player) GetCanSetFullscreen() (bool, error) {
+	return getBool(p, appInterface, "CanSetFullscreen")
 }
 
 func (p *Player) CanSetFullscreen() bool {
-	return getBool(p.obj, appInterface, "CanSetFullscreen")
+	value, _ := p.GetCanSetFullscreen()
+	return value
+}
+
+// Get the value of Fullscreen. Returns an error if it can't be read.
+func (p *Player) GetFullscreen() (bool, error) {
+	return getBool(p, appInterface, "Fullscreen")
+}
+
+// Set the value of Fullscreen. Returns an error if it can't be written.
+func (p *Player) SetFullscreen(value bool) error {
+	return setProp(p, appInterface, "Fullscreen", value)
 }
 
 // Get or set the value of fullscreen. If a parameter is supplied, it will set.
 func (p *Player) Fullscreen(value ...bool) bool {
 	if len(value) == 1 {
-		setProp(p.obj, appInterface, "Fullscreen", value[0])
+		p.SetFullscreen(value[0])
 		return value[0]
 	} else {
-		return getBool(p.obj, appInterface, "Fullscreen")
+		value, _ := p.GetFullscreen()
+		return value
 	}
 }
 
+// Get the value of HasTrackList. Returns an error if it can't be read.
+func (p *Player) GetHasTrackList() (bool, error) {
+	return getBool(p, appInterface, "HasTrackList")
+}
+
 func (p *Player) HasTrackList() bool {
-	return getBool(p.obj, appInterface, "HasTrackList")
+	value, _ := p.GetHasTrackList()
+	return value
+}
+
+// Get the value of SupportedUriSchemes. Returns an error if it can't be read.
+func (p *Player) GetSupportedUriSchemes() ([]string, error) {
+	return getStringList(p, appInterface, "SupportedUriSchemes")
 }
 
 func (p *Player) SupportedUriSchemes() []string {
-	return getStringList(p.obj, appInterface, "SupportedUriSchemes")
+	value, _ := p.GetSupportedUriSchemes()
+	return value
+}
+
+// Get the value of SupportedMimeTypes. Returns an error if it can't be read.
+func (p *Player) GetSupportedMimeTypes() ([]string, error) {
+	return getStringList(p, appInterface, "SupportedMimeTypes")
 }
 
 func (p *Player) SupportedMimeTypes() []string {
-	return getStringList(p.obj, appInterface, "SupportedMimeTypes")
+	value, _ := p.GetSupportedMimeTypes()
+	return value
 }
 
 // Properties on playback: org.mpris.MediaPlayer2.Player
 
+// Get the value of Shuffle. Returns an error if it can't be read.
+func (p *Player) GetShuffle() (bool, error) {
+	return getBool(p, playerInterface, "Shuffle")
+}
+
+// Set the value of Shuffle. Returns an error if it can't be written.
+func (p *Player) SetShuffle(value bool) error {
+	return setProp(p, playerInterface, "Shuffle", value)
+}
+
 // Get or set the value of shuffle. If a parameter is supplied, it will set.
 func (p *Player) Shuffle(value ...bool) bool {
 	if len(value) == 1 {
-		setProp(p.obj, playerInterface, "Shuffle", value[0])
+		p.SetShuffle(value[0])
 		return value[0]
 	} else {
-		return getBool(p.obj, playerInterface, "Shuffle")
+		value, _ := p.GetShuffle()
+		return value
 	}
 }
 
+// Get the value of Rate. Returns an error if it can't be read.
+func (p *Player) GetRate() (float64, error) {
+	return getDouble(p, playerInterface, "Rate")
+}
+
+// Set the value of Rate. Returns an error if it can't be written.
+func (p *Player) SetRate(value float64) error {
+	return setProp(p, playerInterface, "Rate", value)
+}
+
 // Get or set the value of rate. If a parameter is supplied, it will set.
 func (p *Player) Rate(value ...float64) float64 {
 	if len(value) == 1 {
-		setProp(p.obj, playerInterface, "Rate", value[0])
+		p.SetRate(value[0])
 		return value[0]
 	} else {
-		return getDouble(p.obj, playerInterface, "Rate")
+		value, _ := p.GetRate()
+		return value
 	}
 }
 
+// Get the value of Volume. Returns an error if it can't be read.
+func (p *Player) GetVolume() (float64, error) {
+	return getDouble(p, playerInterface, "Volume")
+}
+
+// Set the value of Volume. Returns an error if it can't be written.
+func (p *Player) SetVolume(value float64) error {
+	return setProp(p, playerInterface, "Volume", value)
+}
+
 // Get or set the value of volume. If a parameter is supplied, it will set.
 func (p *Player) Volume(value ...float64) float64 {
 	if len(value) == 1 {
-		setProp(p.obj, playerInterface, "Volume", value[0])
+		p.SetVolume(value[0])
 		return value[0]
 	} else {
-		return getDouble(p.obj, playerInterface, "Volume")
+		value, _ := p.GetVolume()
+		return value
 	}
 }
 
+// Get the value of MaximumRate. Returns an error if it can't be read.
+func (p *Player) GetMaximumRate() (float64, error) {
+	return getDouble(p, playerInterface, "MaximumRate")
+}
+
 func (p *Player) MaximumRate() float64 {
-	return getDouble(p.obj, playerInterface, "MaximumRate")
+	value, _ := p.GetMaximumRate()
+	return value
+}
+
+// Get the value of MinimumRate. Returns an error if it can't be read.
+func (p *Player) GetMinimumRate() (float64, error) {
+	return getDouble(p, playerInterface, "MinimumRate")
 }
 
 func (p *Player) MinimumRate() float64 {
-	return getDouble(p.obj, playerInterface, "MinimumRate")
+	value, _ := p.GetMinimumRate()
+	return value
+}
+
+// Get the value of Position. Returns an error if it can't be read.
+func (p *Player) GetPosition() (int64, error) {
+	return getInt(p, playerInterface, "Position")
 }
 
 func (p *Player) Position() int64 {
-	return getInt(p.obj, playerInterface, "Position")
+	value, _ := p.GetPosition()
+	return value
+}
+
+// Get the value of LoopStatus. Returns an error if it can't be read.
+func (p *Player) GetLoopStatus() (LoopState, error) {
+	value, err := getString(p, playerInterface, "LoopStatus")
+	return LoopState(value), err
+}
+
+// Set the value of LoopStatus. Returns an error if the value isn't one of the
+// LoopState constants or it can't be written.
+func (p *Player) SetLoopStatus(value LoopState) error {
+	if !value.Valid() {
+		return fmt.Errorf("%w: LoopStatus %q", ErrInvalidValue, value)
+	}
+	return setProp(p, playerInterface, "LoopStatus", string(value))
+}
+
+// Get or set the value of loop status. If a parameter is supplied, it will set.
+func (p *Player) LoopStatus(value ...LoopState) LoopState {
+	if len(value) == 1 {
+		p.SetLoopStatus(value[0])
+		return value[0]
+	} else {
+		value, _ := p.GetLoopStatus()
+		return value
+	}
 }
 
-func (p *Player) LoopStatus() LoopState {
-	return LoopState(getString(p.obj, playerInterface, "LoopStatus"))
+// Move to the next loop status, going None, Track, Playlist and back to None.
+// Returns the new status.
+func (p *Player) CycleLoopStatus() (LoopState, error) {
+	current, err := p.GetLoopStatus()
+	if err != nil {
+		return current, err
+	}
+	next := current.Next()
+	return next, p.SetLoopStatus(next)
+}
+
+// Get the value of PlaybackStatus. Returns an error if it can't be read.
+func (p *Player) GetPlaybackStatus() (PlaybackState, error) {
+	value, err := getString(p, playerInterface, "PlaybackStatus")
+	return PlaybackState(value), err
 }
 
 func (p *Player) PlaybackStatus() PlaybackState {
-	return PlaybackState(getString(p.obj, playerInterface, "PlaybackStatus"))
+	value, _ := p.GetPlaybackStatus()
+	return value
 }
 
-func (p *Player) RawMetadata() map[string]dbus.Variant {
-	result, err := getProp(p.obj, playerInterface, "Metadata")
+// Get the metadata of the current track, decoded into typed fields. Returns
+// an error if it can't be read.
+func (p *Player) GetMetadata() (Metadata, error) {
+	raw, err := p.GetRawMetadata()
+	return ParseMetadata(raw), err
+}
+
+// Get the metadata of the current track, decoded into typed fields.
+func (p *Player) Metadata() Metadata {
+	value, _ := p.GetMetadata()
+	return value
+}
+
+// Get the metadata of the current track as sent by the player. Returns an
+// error if it can't be read.
+func (p *Player) GetRawMetadata() (map[string]dbus.Variant, error) {
+	result, err := getProp(p, playerInterface, "Metadata")
 	if err != nil {
-		return make(map[string]dbus.Variant)
-	} else {
-		return result.Value().(map[string]dbus.Variant)
+		return make(map[string]dbus.Variant), err
 	}
+	meta, ok := result.Value().(map[string]dbus.Variant)
+	if !ok {
+		return make(map[string]dbus.Variant), wrongType(playerInterface, "Metadata", result.Value())
+	}
+	return meta, nil
+}
+
+// Get the metadata of the current track as sent by the player.
+func (p *Player) RawMetadata() map[string]dbus.Variant {
+	value, _ := p.GetRawMetadata()
+	return value
+}
+
+// Get the value of CanGoNext. Returns an error if it can't be read.
+func (p *Player) GetCanGoNext() (bool, error) {
+	return getBool(p, playerInterface, "CanGoNext")
 }
 
 func (p *Player) CanGoNext() bool {
-	return getBool(p.obj, playerInterface, "CanGoNext")
+	value, _ := p.GetCanGoNext()
+	return value
+}
+
+// Get the value of CanGoPrevious. Returns an error if it can't be read.
+func (p *Player) GetCanGoPrevious() (bool, error) {
+	return getBool(p, playerInterface, "CanGoPrevious")
 }
 
 func (p *Player) CanGoPrevious() bool {
-	return getBool(p.obj, playerInterface, "CanGoPrevious")
+	value, _ := p.GetCanGoPrevious()
+	return value
+}
+
+// Get the value of CanPlay. Returns an error if it can't be read.
+func (p *Player) GetCanPlay() (bool, error) {
+	return getBool(p, playerInterface, "CanPlay")
 }
 
 func (p *Player) CanPlay() bool {
-	return getBool(p.obj, playerInterface, "CanPlay")
+	value, _ := p.GetCanPlay()
+	return value
+}
+
+// Get the value of CanPause. Returns an error if it can't be read.
+func (p *Player) GetCanPause() (bool, error) {
+	return getBool(p, playerInterface, "CanPause")
 }
 
 func (p *Player) CanPause() bool {
-	return getBool(p.obj, playerInterface, "CanPause")
+	value, _ := p.GetCanPause()
+	return value
+}
+
+// Get the value of CanSeek. Returns an error if it can't be read.
+func (p *Player) GetCanSeek() (bool, error) {
+	return getBool(p, playerInterface, "CanSeek")
 }
 
 func (p *Player) CanSeek() bool {
-	return getBool(p.obj, playerInterface, "CanSeek")
+	value, _ := p.GetCanSeek()
+	return value
+}
+
+// Get the value of CanControl. Returns an error if it can't be read.
+func (p *Player) GetCanControl() (bool, error) {
+	return getBool(p, playerInterface, "CanControl")
 }
 
 func (p *Player) CanControl() bool {
-	return getBool(p.obj, playerInterface, "CanControl")
+	value, _ := p.GetCanControl()
+	return value
 }
 
 //
 // Signals
 //
 
-// Get a channel of signals that playback on this player has seeked.
-func (p *Player) OnSeek() (chan *dbus.Signal, error) {
-	c := make(chan *dbus.Signal, 10)
-	err := p.conn.AddMatchSignal(
-		dbus.WithMatchInterface(playerInterface),
-	)
-	if err != nil {
-		return c, err
-	}
-	p.conn.Signal(c)
-	return c, nil
-}
-
-// Get a channel of signals that this player has changed a property.
-func (p *Player) OnChange() (chan *dbus.Signal, error) {
-	log.Println("OnChange", p.obj.Destination())
-	c := make(chan *dbus.Signal, 10)
-	err := p.conn.AddMatchSignal(
-		dbus.WithMatchSender(p.Owner),
-		dbus.WithMatchObjectPath(objectPath),
-		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
-	)
-	if err != nil {
-		return c, err
+// Subscribe to a given signal emitted by this player. Call Unsubscribe on the
+// result when finished.
+func (p *Player) Subscribe(iface, member string) (*Subscription, error) {
+	return p.signals.subscribe(p.matchRule(iface, member))
+}
+
+// Get a rule matching a given signal emitted by this player.
+func (p *Player) matchRule(iface, member string) MatchRule {
+	return MatchRule{
+		Sender:    p.Owner,
+		Path:      objectPath,
+		Interface: iface,
+		Member:    member,
 	}
-	p.conn.Signal(c)
-	return c, nil
+}
+
+// Get a channel of events that playback on this player has seeked. The channel
+// is closed when the player's context is done.
+func (p *Player) OnSeek() (chan SeekedEvent, error) {
+	c := make(chan SeekedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(playerInterface, seekedMember), func(signal *dbus.Signal) {
+		if event, ok := parseSeeked(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
+}
+
+// Get a channel of events that this player has changed a property. The channel
+// is closed when the player's context is done.
+func (p *Player) OnChange() (chan PropertiesChangedEvent, error) {
+	c := make(chan PropertiesChangedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(propertyInterface, propertiesChangedMember), func(signal *dbus.Signal) {
+		if event, ok := parsePropertiesChanged(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
 }
@@ -0,0 +1,57 @@
+package mpris
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"chrom*", "chromium", true},
+		{"chrom*", "chrome", true},
+		{"chrom*", "firefox", false},
+		{"vl?", "vlc", true},
+		{"[sv]*", "spotify", true},
+		{"/^vlc/", "vlc.instance1234", true},
+		{"/^vlc$/", "vlc.instance1234", false},
+		{"/fire|chrom/", "firefox", true},
+		{"/[/", "[", false},
+		{"[", "[", false},
+		{"//", "/", false},
+		{"vlc", "vlc", false},
+	}
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.value); got != test.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", test.pattern, test.value, got, test.want)
+		}
+	}
+}
+
+func TestPlayerMatches(t *testing.T) {
+	vlc := &Player{Name: "org.mpris.MediaPlayer2.vlc.instance1234"}
+	spotify := &Player{Name: "org.mpris.MediaPlayer2.spotify"}
+
+	tests := []struct {
+		player *Player
+		name   string
+		want   bool
+	}{
+		{spotify, "spotify", true},
+		{spotify, "org.mpris.MediaPlayer2.spotify", true},
+		{spotify, "spot", false},
+		{spotify, "Spotify", false},
+		{vlc, "vlc", true},
+		{vlc, "vlc.instance1234", true},
+		{vlc, "vlc.instance", false},
+		{vlc, "v*", true},
+		{vlc, "org.mpris.*", true},
+		{vlc, "/instance[0-9]+$/", true},
+		{vlc, "/^spotify$/", false},
+		{spotify, "*fy", true},
+	}
+	for _, test := range tests {
+		if got := test.player.Matches(test.name); got != test.want {
+			t.Errorf("%s Matches(%q) = %v, want %v", test.player.Name, test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package mpris
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// A playlist provided by a player. The ID is an object path unique to the
+// player, and Icon is an optional URI for an image to represent it.
+type Playlist struct {
+	ID   string
+	Name string
+	Icon string
+}
+
+// Ways a player can sort its playlists, implemented as a type to act like an enum.
+type PlaylistOrdering string
+
+const (
+	OrderAlphabetical PlaylistOrdering = "Alphabetical"
+	OrderCreationDate PlaylistOrdering = "CreationDate"
+	OrderModifiedDate PlaylistOrdering = "ModifiedDate"
+	OrderLastPlayDate PlaylistOrdering = "LastPlayDate"
+	OrderUserDefined  PlaylistOrdering = "UserDefined"
+)
+
+// The D-Bus representation of a playlist: (oss)
+type rawPlaylist struct {
+	ID   dbus.ObjectPath
+	Name string
+	Icon string
+}
+
+func (r rawPlaylist) playlist() Playlist {
+	return Playlist{ID: string(r.ID), Name: r.Name, Icon: r.Icon}
+}
+
+func (p Playlist) raw() rawPlaylist {
+	return rawPlaylist{ID: dbus.ObjectPath(p.ID), Name: p.Name, Icon: p.Icon}
+}
+
+// The D-Bus representation of a playlist which may not exist: (b(oss))
+type rawMaybePlaylist struct {
+	Valid    bool
+	Playlist rawPlaylist
+}
+
+//
+// Methods
+//
+
+// Methods on playlists: org.mpris.MediaPlayer2.Playlists
+
+// Start playing the playlist with the given ID.
+func (p *Player) ActivatePlaylist(playlistId string) error {
+	path := dbus.ObjectPath(playlistId)
+	call := p.call(playlistsInterface+".ActivatePlaylist", path)
+	return call.Err
+}
+
+// Get a page of playlists. Start at index and return at most maxCount
+// playlists, sorted by the given ordering and optionally reversed.
+func (p *Player) GetPlaylists(index, maxCount uint32, order PlaylistOrdering, reverse bool) ([]Playlist, error) {
+	var raw []rawPlaylist
+	err := p.call(playlistsInterface+".GetPlaylists", index, maxCount, string(order), reverse).Store(&raw)
+	if err != nil {
+		return nil, err
+	}
+	playlists := make([]Playlist, len(raw))
+	for i, r := range raw {
+		playlists[i] = r.playlist()
+	}
+	return playlists, nil
+}
+
+//
+// Properties
+//
+
+// Properties on playlists: org.mpris.MediaPlayer2.Playlists
+
+// Get the number of playlists available. Returns an error if it can't be read.
+func (p *Player) GetPlaylistCount() (uint32, error) {
+	return getUint(p, playlistsInterface, "PlaylistCount")
+}
+
+// Get the number of playlists available.
+func (p *Player) PlaylistCount() uint32 {
+	value, _ := p.GetPlaylistCount()
+	return value
+}
+
+// Get the orderings the player supports in GetPlaylists. Returns an error if
+// they can't be read.
+func (p *Player) GetOrderings() ([]PlaylistOrdering, error) {
+	list, err := getStringList(p, playlistsInterface, "Orderings")
+	orderings := make([]PlaylistOrdering, len(list))
+	for i, order := range list {
+		orderings[i] = PlaylistOrdering(order)
+	}
+	return orderings, err
+}
+
+// Get the orderings the player supports in GetPlaylists.
+func (p *Player) Orderings() []PlaylistOrdering {
+	value, _ := p.GetOrderings()
+	return value
+}
+
+// Get the currently active playlist. The second value is false if there is no
+// active playlist. Returns an error if it can't be read.
+func (p *Player) GetActivePlaylist() (Playlist, bool, error) {
+	result, err := getProp(p, playlistsInterface, "ActivePlaylist")
+	if err != nil {
+		return Playlist{}, false, err
+	}
+	var maybe rawMaybePlaylist
+	if err := dbus.Store([]interface{}{result.Value()}, &maybe); err != nil {
+		return Playlist{}, false, wrongType(playlistsInterface, "ActivePlaylist", result.Value())
+	}
+	return maybe.Playlist.playlist(), maybe.Valid, nil
+}
+
+// Get the currently active playlist. The second value is false if there is no
+// active playlist.
+func (p *Player) ActivePlaylist() (Playlist, bool) {
+	playlist, ok, _ := p.GetActivePlaylist()
+	return playlist, ok
+}
+
+//
+// Signals
+//
+
+// The name or icon of one of the player's playlists has changed.
+type PlaylistChangedEvent struct {
+	Player   *Player
+	Playlist Playlist // The playlist with its new name and icon
+}
+
+// Get a channel of events that a playlist's name or icon has changed. The
+// channel is closed when the player's context is done.
+func (p *Player) OnPlaylistChanged() (chan PlaylistChangedEvent, error) {
+	c := make(chan PlaylistChangedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(playlistsInterface, "PlaylistChanged"), func(signal *dbus.Signal) {
+		if event, ok := parsePlaylistChanged(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
+}
+
+// Decode a PlaylistChanged signal. Returns false if the body is malformed.
+func parsePlaylistChanged(player *Player, signal *dbus.Signal) (PlaylistChangedEvent, bool) {
+	var raw rawPlaylist
+	if len(signal.Body) != 1 || dbus.Store(signal.Body, &raw) != nil {
+		return PlaylistChangedEvent{}, false
+	}
+	return PlaylistChangedEvent{Player: player, Playlist: raw.playlist()}, true
+}
@@ -1,8 +1,6 @@
 package mpris
 
 import (
-	"log"
-
 	"github.com/godbus/dbus/v5"
 )
 
@@ -13,30 +11,20 @@ const (
 	introspectMethod     = "org.freedesktop.DBus.Introspectable.Introspect"
 )
 
-// Get a given property on a given interface of this object.
-func (p *Player) Get(iface, prop string) (result dbus.Variant, err error) {
-	err = p.obj.Call(getPropertyMethod, 0, iface, prop).Store(&result)
-	if err != nil {
-		log.Println("Error getting property: ", err)
-	}
-	return
+// Get a given property on a given interface of this object. Errors can be
+// compared against ErrNotSupported, ErrPlayerGone and ErrTimeout.
+func (p *Player) Get(iface, prop string) (dbus.Variant, error) {
+	return getProp(p, iface, prop)
 }
 
 // Get all properties on a given interface of this object.
 func (p *Player) GetAll(iface string) (result map[string]dbus.Variant, err error) {
-	err = p.obj.Call(getAllPropertyMethod, 0, iface).Store(&result)
-	if err != nil {
-		log.Println("Error getting all properties: ", err, p.obj)
-	}
-	return
+	err = p.call(getAllPropertyMethod, iface).Store(&result)
+	return result, err
 }
 
-// Set a given property on a given interface of this object.
-func (p *Player) Set(iface, prop string, value interface{}) (err error) {
-	call := p.obj.Call(setPropertyMethod, 0, iface, prop, value)
-	err = call.Err
-	if err != nil {
-		log.Println("Error setting property: ", err)
-	}
-	return
+// Set a given property on a given interface of this object. Values which
+// aren't already a dbus.Variant are wrapped in one, as the method requires.
+func (p *Player) Set(iface, prop string, value interface{}) error {
+	return setProp(p, iface, prop, value)
 }
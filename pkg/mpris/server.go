@@ -1,10 +1,13 @@
 package mpris
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
@@ -97,6 +100,7 @@ const (
 		<property name="CanRaise" type="b" access="read"></property>
 		<property name="HasTrackList" type="b" access="read"></property>
 		<property name="Identity" type="s" access="read"></property>
+		<property name="DesktopEntry" type="s" access="read"></property>
 		<property name="SupportedUriSchemes" type="as" access="read"></property>
 		<property name="SupportedMimeTypes" type="as" access="read"></property>
 	</interface>
@@ -121,8 +125,24 @@ type Server struct {
 	PlayerServer    IsPlayer
 	PropertyHandler HandlesProperties
 
-	def    introspect.Node
-	custom map[string]interface{}
+	// Optional handlers. The matching interface is only published when set.
+	TrackListServer IsTrackList
+	PlaylistsServer HasPlaylists
+
+	// The values of all MPRIS properties. This answers property requests
+	// unless PropertyHandler is set.
+	Properties *PropertyStore
+
+	// Called when another process takes over our bus name, just before Serve
+	// shuts down and returns ErrNameLost.
+	OnNameLost func()
+
+	custom  map[string]customInterface
+	signals *dispatcher
+
+	mu       sync.Mutex
+	stop     chan struct{}
+	exported []string
 }
 
 // This interface allows an object to handle all needed requests for
@@ -160,54 +180,251 @@ func NewServer(name string) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
-	server := Server{def: mprisIntrospect, Conn: conn, Name: name}
-	server.custom = make(map[string]interface{})
+	server := Server{Conn: conn, Name: name}
+	server.custom = make(map[string]customInterface)
+	server.Properties = NewPropertyStore(conn, mprisIntrospect, defaultProperties(name))
+	server.Properties.Declare(trackListIntrospect, nil)
+	server.Properties.Declare(playlistsIntrospect, nil)
 	return &server, nil
 }
 
-// Release the claimed bus name and close the connection.
-func (s *Server) Close() {
-	s.Conn.ReleaseName(appInterface + "." + s.Name)
-	s.Conn.Close()
+// Get the values properties start with, following the MPRIS spec where it has
+// an opinion.
+func defaultProperties(name string) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		appInterface: {
+			"Identity": name,
+		},
+		playerInterface: {
+			"PlaybackStatus": string(PlaybackStopped),
+			"LoopStatus":     string(LoopNone),
+			"Rate":           1.0,
+			"Volume":         1.0,
+			"MinimumRate":    1.0,
+			"MaximumRate":    1.0,
+			"CanControl":     true,
+		},
+	}
+}
+
+// Tell clients that playback has jumped to a new position, in microseconds.
+// This also updates the Position property.
+func (s *Server) Seeked(position int64) error {
+	if err := s.Properties.SetPosition(position); err != nil {
+		return err
+	}
+	return s.Conn.Emit(objectPath, playerInterface+"."+seekedMember, position)
+}
+
+// Tell clients that properties have changed. This is only needed when using
+// a custom PropertyHandler, since the Properties store emits this itself.
+func (s *Server) EmitPropertiesChanged(iface string, changed map[string]dbus.Variant, invalidated []string) error {
+	return emitPropertiesChanged(s.Conn, iface, changed, invalidated)
 }
 
-// Add a custom interface to your server object.
-func (s *Server) AddInterface(name string, handler interface{}) {
-	s.custom[name] = handler
-	customInt := introspect.Interface{
-		Name:    name,
-		Methods: introspect.Methods(handler),
+// Get the full bus name this server claims.
+func (s *Server) BusName() string {
+	return appInterface + "." + s.Name
+}
+
+// Shut down the server and close the connection.
+func (s *Server) Close() error {
+	err := s.Shutdown()
+	if closeErr := s.Conn.Close(); err == nil {
+		err = closeErr
 	}
-	s.def.Interfaces = append(s.def.Interfaces, customInt)
+	return err
+}
+
+// Tell clients about a signal on one of your custom interfaces.
+func (s *Server) Emit(iface, signal string, args ...interface{}) error {
+	return s.Conn.Emit(objectPath, iface+"."+signal, args...)
 }
 
-// Start the server and block.
+// Add a custom interface to your server object. Its exported methods are
+// published, along with any properties and signals declared in struct tags.
+// Declared properties are added to Properties.
+func (s *Server) AddInterface(name string, handler interface{}) error {
+	def, defaults, err := describeInterface(name, handler)
+	if err != nil {
+		return err
+	}
+	s.custom[name] = customInterface{handler: handler, def: def}
+	if s.Properties != nil {
+		s.Properties.Declare(def, defaults)
+	}
+	return nil
+}
+
+// Start the server and block. This is the same as Serve without a way to stop.
 func (s *Server) Listen() error {
+	return s.Serve(context.Background())
+}
 
-	// First, publish the introspection of the whole server. This is static.
-	s.Conn.Export(
-		introspect.NewIntrospectable(&s.def),
-		objectPath,
-		introspectableInterface)
+// Publish the server and claim its bus name, then block until the context is
+// done, Shutdown is called, or the name is lost. Returns nil after a clean
+// shutdown, and ErrNameLost if another process took over the name.
+func (s *Server) Serve(ctx context.Context) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
 
-	// Export all our known objects as interfaces on the objects.
-	s.Conn.Export(s.PropertyHandler, objectPath, propertyInterface)
-	s.Conn.Export(s.AppServer, objectPath, appInterface)
-	s.Conn.Export(s.PlayerServer, objectPath, playerInterface)
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return errors.New("Server is already running")
+	}
+	stop := make(chan struct{})
+	s.stop = stop
+	if s.signals == nil {
+		s.signals = newDispatcher(s.Conn)
+	}
+	s.mu.Unlock()
 
-	// Export all our custom interfaces to the object.
-	for name, handler := range s.custom {
-		s.Conn.Export(handler, objectPath, name)
+	// Watch for losing the name before claiming it, so it can't be missed.
+	lost, err := s.signals.subscribe(MatchRule{
+		Sender:    busName,
+		Interface: busName,
+		Member:    "NameLost",
+	})
+	if err != nil {
+		s.Shutdown()
+		return err
 	}
+	defer lost.Unsubscribe()
 
-	// Now let's name our server
-	serverName := appInterface + "." + s.Name
-	reply, err := s.Conn.RequestName(serverName, dbus.NameFlagReplaceExisting)
-	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
-		return errors.New("Unable to claim " + serverName)
+	if err := s.export(); err != nil {
+		s.Shutdown()
+		return err
+	}
+
+	// Now let's name our server. Newer instances may take the name over.
+	serverName := s.BusName()
+	reply, err := s.Conn.RequestName(serverName, dbus.NameFlagReplaceExisting|dbus.NameFlagAllowReplacement)
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		err = errors.New("Unable to claim " + serverName)
+	}
+	if err != nil {
+		s.Shutdown()
+		return err
 	}
 
 	log.Println("Started DBus server on " + serverName)
 
-	select {}
+	for {
+		select {
+		case <-ctx.Done():
+			return s.Shutdown()
+		case <-stop:
+			return nil
+		case signal, ok := <-lost.C:
+			if !ok {
+				s.Shutdown()
+				return dbus.ErrClosed
+			}
+			if len(signal.Body) == 0 || signal.Body[0] != serverName {
+				continue
+			}
+
+			// Releasing the name during Shutdown also sends NameLost.
+			s.mu.Lock()
+			stopping := s.stop != stop
+			s.mu.Unlock()
+			if stopping {
+				<-stop
+				return nil
+			}
+			log.Println("Lost DBus name " + serverName)
+			if s.OnNameLost != nil {
+				s.OnNameLost()
+			}
+			s.Shutdown()
+			return ErrNameLost
+		}
+	}
+}
+
+// Stop serving: release the bus name and remove every exported interface.
+// This does nothing if the server isn't running.
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	stop := s.stop
+	exported := s.exported
+	s.stop = nil
+	s.exported = nil
+	s.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	defer close(stop)
+
+	var errs []string
+	reply, err := s.Conn.ReleaseName(s.BusName())
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else if reply == dbus.ReleaseNameReplyNonExistent {
+		errs = append(errs, "Name "+s.BusName()+" does not exist")
+	}
+	for _, iface := range exported {
+		if err := s.Conn.Export(nil, objectPath, iface); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Unable to shut down cleanly: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Export all our known objects as interfaces on the object. Each exported
+// interface is remembered so Shutdown can remove it.
+func (s *Server) export() error {
+	exports := make(map[string]interface{})
+
+	// First, publish the introspection of what we're about to export.
+	def := s.introspection()
+	exports[introspectableInterface] = introspect.NewIntrospectable(&def)
+
+	// Only show properties of the optional interfaces we have handlers for.
+	if s.Properties != nil {
+		var hidden []string
+		if s.TrackListServer != nil {
+			s.Properties.SetHasTrackList(true)
+		} else {
+			hidden = append(hidden, trackListInterface)
+		}
+		if s.PlaylistsServer == nil {
+			hidden = append(hidden, playlistsInterface)
+		}
+		s.Properties.hide(hidden...)
+	}
+
+	if s.PropertyHandler != nil {
+		exports[propertyInterface] = s.PropertyHandler
+	} else {
+		exports[propertyInterface] = s.Properties
+	}
+	exports[appInterface] = s.AppServer
+	exports[playerInterface] = s.PlayerServer
+	if s.TrackListServer != nil {
+		exports[trackListInterface] = trackListExport{s.TrackListServer}
+	}
+	if s.PlaylistsServer != nil {
+		exports[playlistsInterface] = playlistsExport{s.PlaylistsServer}
+	}
+
+	// Export all our custom interfaces to the object.
+	for name, custom := range s.custom {
+		exports[name] = custom.handler
+	}
+
+	for iface, handler := range exports {
+		if err := s.Conn.Export(handler, objectPath, iface); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.exported = append(s.exported, iface)
+		s.mu.Unlock()
+	}
+	return nil
 }
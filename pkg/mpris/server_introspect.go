@@ -0,0 +1,189 @@
+package mpris
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// The struct tag used to describe properties and signals of custom interfaces.
+//
+//   type customServer struct {
+//       Level   int32                  `mpris:"property,readwrite"`
+//       Ready   bool                   `mpris:"property,name=IsReady,emits=false"`
+//       Renamed struct{ Name string }  `mpris:"signal"`
+//   }
+//
+// Properties take their type from the field and start with the field's value,
+// after which they live in Server.Properties. Access is one of read, write or
+// readwrite and defaults to read. Signals are declared with a struct field
+// whose fields are the signal's arguments, and sent with Server.Emit.
+const structTag = "mpris"
+
+// A custom interface added to the server, along with its description.
+type customInterface struct {
+	handler interface{}
+	def     introspect.Interface
+}
+
+// Describe a handler as an interface: its exported methods, plus any
+// properties and signals declared with struct tags. Also returns the starting
+// values of the properties.
+func describeInterface(name string, handler interface{}) (introspect.Interface, map[string]interface{}, error) {
+	iface := introspect.Interface{
+		Name:    name,
+		Methods: introspect.Methods(handler),
+	}
+	defaults := make(map[string]interface{})
+
+	v := reflect.ValueOf(handler)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return iface, defaults, nil
+	}
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup(structTag)
+		if !ok {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		member := field.Name
+		access := AccessRead
+		var emits string
+		for _, option := range options[1:] {
+			switch {
+			case strings.HasPrefix(option, "name="):
+				member = strings.TrimPrefix(option, "name=")
+			case strings.HasPrefix(option, "emits="):
+				emits = strings.TrimPrefix(option, "emits=")
+			case option == string(AccessRead), option == string(AccessWrite), option == string(AccessReadWrite):
+				access = Access(option)
+			default:
+				return iface, nil, fmt.Errorf("%s.%s: unknown tag option %q", name, field.Name, option)
+			}
+		}
+
+		switch options[0] {
+		case "property":
+			signature, err := signatureOf(field.Type)
+			if err != nil {
+				return iface, nil, fmt.Errorf("%s.%s: %v", name, field.Name, err)
+			}
+			prop := introspect.Property{
+				Name:   member,
+				Type:   signature,
+				Access: string(access),
+			}
+			if emits != "" {
+				prop.Annotations = []introspect.Annotation{{Name: emitsChangedAnnotation, Value: emits}}
+			}
+			iface.Properties = append(iface.Properties, prop)
+			if field.PkgPath == "" {
+				defaults[member] = v.Field(i).Interface()
+			} else {
+				defaults[member] = reflect.Zero(field.Type).Interface()
+			}
+		case "signal":
+			if field.Type.Kind() != reflect.Struct {
+				return iface, nil, fmt.Errorf("%s.%s: signal must be a struct of its arguments", name, field.Name)
+			}
+			signal := introspect.Signal{Name: member}
+			for j := 0; j < field.Type.NumField(); j++ {
+				arg := field.Type.Field(j)
+				signature, err := signatureOf(arg.Type)
+				if err != nil {
+					return iface, nil, fmt.Errorf("%s.%s.%s: %v", name, field.Name, arg.Name, err)
+				}
+				signal.Args = append(signal.Args, introspect.Arg{
+					Name: arg.Name,
+					Type: signature,
+				})
+			}
+			iface.Signals = append(iface.Signals, signal)
+		default:
+			return iface, nil, fmt.Errorf("%s.%s: unknown tag kind %q", name, field.Name, options[0])
+		}
+	}
+	return iface, defaults, nil
+}
+
+// Get the D-Bus signature of a Go type. godbus panics on types D-Bus can't
+// represent, like funcs and channels, so that is turned into an error.
+func signatureOf(t reflect.Type) (signature string, err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("%v can't be sent over D-Bus", t)
+		}
+	}()
+	return dbus.SignatureOfType(t).String(), nil
+}
+
+// Find a standard interface in the MPRIS introspection data.
+func standardInterface(name string) introspect.Interface {
+	for _, iface := range mprisIntrospect.Interfaces {
+		if iface.Name == name {
+			return iface
+		}
+	}
+	return introspect.Interface{Name: name}
+}
+
+// Build the introspection of the object from the handlers registered on the
+// server, so clients are only told about interfaces that will answer.
+func (s *Server) introspection() introspect.Node {
+	node := introspect.Node{Name: string(objectPath)}
+	node.Interfaces = append(node.Interfaces,
+		standardInterface("org.freedesktop.DBus.Peer"),
+		standardInterface(introspectableInterface),
+		standardInterface(propertyInterface))
+
+	if s.AppServer != nil {
+		node.Interfaces = append(node.Interfaces, standardInterface(appInterface))
+	}
+	if s.PlayerServer != nil {
+		node.Interfaces = append(node.Interfaces, standardInterface(playerInterface))
+	}
+	if s.TrackListServer != nil {
+		node.Interfaces = append(node.Interfaces, trackListIntrospect)
+	}
+	if s.PlaylistsServer != nil {
+		node.Interfaces = append(node.Interfaces, playlistsIntrospect)
+	}
+
+	names := make([]string, 0, len(s.custom))
+	for name := range s.custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node.Interfaces = append(node.Interfaces, s.custom[name].def)
+	}
+	return node
+}
+
+// Make sure every interface MPRIS requires has a handler.
+func (s *Server) validate() error {
+	if s.Conn == nil {
+		return errors.New("Server has no connection")
+	}
+	if s.AppServer == nil {
+		return errors.New("Server needs an AppServer to handle " + appInterface)
+	}
+	if s.PlayerServer == nil {
+		return errors.New("Server needs a PlayerServer to handle " + playerInterface)
+	}
+	if s.PropertyHandler == nil && s.Properties == nil {
+		return errors.New("Server needs a PropertyHandler or Properties to handle " + propertyInterface)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package mpris
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	trackListIntrospectXML = `<node>
+	<interface name="org.mpris.MediaPlayer2.TrackList">
+		<method name="GetTracksMetadata">
+			<arg name="TrackIds" type="ao" direction="in"></arg>
+			<arg name="Metadata" type="aa{sv}" direction="out"></arg>
+		</method>
+		<method name="AddTrack">
+			<arg name="Uri" type="s" direction="in"></arg>
+			<arg name="AfterTrack" type="o" direction="in"></arg>
+			<arg name="SetAsCurrent" type="b" direction="in"></arg>
+		</method>
+		<method name="RemoveTrack">
+			<arg name="TrackId" type="o" direction="in"></arg>
+		</method>
+		<method name="GoTo">
+			<arg name="TrackId" type="o" direction="in"></arg>
+		</method>
+		<signal name="TrackListReplaced">
+			<arg name="Tracks" type="ao"></arg>
+			<arg name="CurrentTrack" type="o"></arg>
+		</signal>
+		<signal name="TrackAdded">
+			<arg name="Metadata" type="a{sv}"></arg>
+			<arg name="AfterTrack" type="o"></arg>
+		</signal>
+		<signal name="TrackRemoved">
+			<arg name="TrackId" type="o"></arg>
+		</signal>
+		<signal name="TrackMetadataChanged">
+			<arg name="TrackId" type="o"></arg>
+			<arg name="Metadata" type="a{sv}"></arg>
+		</signal>
+		<property name="Tracks" type="ao" access="read">
+			<annotation name="org.freedesktop.DBus.Property.EmitsChangedSignal" value="invalidates"></annotation>
+		</property>
+		<property name="CanEditTracks" type="b" access="read"></property>
+	</interface>
+</node>
+`
+
+	playlistsIntrospectXML = `<node>
+	<interface name="org.mpris.MediaPlayer2.Playlists">
+		<method name="ActivatePlaylist">
+			<arg name="PlaylistId" type="o" direction="in"></arg>
+		</method>
+		<method name="GetPlaylists">
+			<arg name="Index" type="u" direction="in"></arg>
+			<arg name="MaxCount" type="u" direction="in"></arg>
+			<arg name="Order" type="s" direction="in"></arg>
+			<arg name="ReverseOrder" type="b" direction="in"></arg>
+			<arg name="Playlists" type="a(oss)" direction="out"></arg>
+		</method>
+		<signal name="PlaylistChanged">
+			<arg name="Playlist" type="(oss)"></arg>
+		</signal>
+		<property name="PlaylistCount" type="u" access="read"></property>
+		<property name="Orderings" type="as" access="read"></property>
+		<property name="ActivePlaylist" type="(b(oss))" access="read"></property>
+	</interface>
+</node>
+`
+)
+
+var trackListIntrospect = parseInterface(trackListIntrospectXML)
+var playlistsIntrospect = parseInterface(playlistsIntrospectXML)
+
+// Parse the first interface out of an introspection document.
+func parseInterface(data string) introspect.Interface {
+	var node introspect.Node
+	xml.NewDecoder(strings.NewReader(data)).Decode(&node)
+	return node.Interfaces[0]
+}
+
+// This interface allows an object to handle all needed requests for
+//   org.mpris.MediaPlayer2.TrackList
+type IsTrackList interface {
+	GetTracksMetadata(trackIds []string) ([]Metadata, *dbus.Error)
+	AddTrack(uri, afterTrack string, setAsCurrent bool) *dbus.Error
+	RemoveTrack(trackId string) *dbus.Error
+	GoTo(trackId string) *dbus.Error
+}
+
+// This interface allows an object to handle all needed requests for
+//   org.mpris.MediaPlayer2.Playlists
+type HasPlaylists interface {
+	ActivatePlaylist(playlistId string) *dbus.Error
+	GetPlaylists(index, maxCount uint32, order PlaylistOrdering, reverse bool) ([]Playlist, *dbus.Error)
+}
+
+// Exports an IsTrackList, translating between D-Bus and Go types.
+type trackListExport struct {
+	handler IsTrackList
+}
+
+func (t trackListExport) GetTracksMetadata(trackIds []dbus.ObjectPath) ([]map[string]dbus.Variant, *dbus.Error) {
+	ids := make([]string, len(trackIds))
+	for i, id := range trackIds {
+		ids[i] = string(id)
+	}
+	tracks, err := t.handler.GetTracksMetadata(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]dbus.Variant, len(tracks))
+	for i, meta := range tracks {
+		result[i] = meta.Map()
+	}
+	return result, nil
+}
+
+func (t trackListExport) AddTrack(uri string, afterTrack dbus.ObjectPath, setAsCurrent bool) *dbus.Error {
+	return t.handler.AddTrack(uri, string(afterTrack), setAsCurrent)
+}
+
+func (t trackListExport) RemoveTrack(trackId dbus.ObjectPath) *dbus.Error {
+	return t.handler.RemoveTrack(string(trackId))
+}
+
+func (t trackListExport) GoTo(trackId dbus.ObjectPath) *dbus.Error {
+	return t.handler.GoTo(string(trackId))
+}
+
+// Exports a HasPlaylists, translating between D-Bus and Go types.
+type playlistsExport struct {
+	handler HasPlaylists
+}
+
+func (p playlistsExport) ActivatePlaylist(playlistId dbus.ObjectPath) *dbus.Error {
+	return p.handler.ActivatePlaylist(string(playlistId))
+}
+
+func (p playlistsExport) GetPlaylists(index, maxCount uint32, order string, reverse bool) ([]rawPlaylist, *dbus.Error) {
+	playlists, err := p.handler.GetPlaylists(index, maxCount, PlaylistOrdering(order), reverse)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]rawPlaylist, len(playlists))
+	for i, playlist := range playlists {
+		result[i] = playlist.raw()
+	}
+	return result, nil
+}
+
+//
+// Signals
+//
+
+// Tell clients the whole track list has been replaced. This also updates the
+// Tracks property.
+func (s *Server) TrackListReplaced(tracks []string, currentTrack string) error {
+	if err := s.Properties.SetTracks(tracks); err != nil {
+		return err
+	}
+	return s.Conn.Emit(objectPath, trackListInterface+".TrackListReplaced", objectPaths(tracks), dbus.ObjectPath(currentTrack))
+}
+
+// Tell clients a track has been added after the given track. Use NoTrack if it
+// was added at the start of the list.
+func (s *Server) TrackAdded(metadata Metadata, afterTrack string) error {
+	return s.Conn.Emit(objectPath, trackListInterface+".TrackAdded", metadata.Map(), dbus.ObjectPath(afterTrack))
+}
+
+// Tell clients a track has been removed from the track list.
+func (s *Server) TrackRemoved(trackId string) error {
+	return s.Conn.Emit(objectPath, trackListInterface+".TrackRemoved", dbus.ObjectPath(trackId))
+}
+
+// Tell clients the metadata of a track in the track list has changed.
+func (s *Server) TrackMetadataChanged(trackId string, metadata Metadata) error {
+	return s.Conn.Emit(objectPath, trackListInterface+".TrackMetadataChanged", dbus.ObjectPath(trackId), metadata.Map())
+}
+
+// Tell clients the name or icon of a playlist has changed.
+func (s *Server) PlaylistChanged(playlist Playlist) error {
+	return s.Conn.Emit(objectPath, playlistsInterface+".PlaylistChanged", playlist.raw())
+}
+
+// Convert a list of IDs into object paths.
+func objectPaths(ids []string) []dbus.ObjectPath {
+	paths := make([]dbus.ObjectPath, len(ids))
+	for i, id := range ids {
+		paths[i] = dbus.ObjectPath(id)
+	}
+	return paths
+}
@@ -0,0 +1,298 @@
+package mpris
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// A set of conditions a signal must meet to be delivered. Empty fields match
+// anything.
+type MatchRule struct {
+	Sender    string
+	Path      dbus.ObjectPath
+	Interface string
+	Member    string
+
+	// Only match signals whose first argument is this bus name or one of its
+	// descendants, like org.mpris.MediaPlayer2 matches org.mpris.MediaPlayer2.vlc
+	Arg0Namespace string
+}
+
+// Get the options to register this rule with the bus.
+func (r MatchRule) options() []dbus.MatchOption {
+	var options []dbus.MatchOption
+	if r.Sender != "" {
+		options = append(options, dbus.WithMatchSender(r.Sender))
+	}
+	if r.Path != "" {
+		options = append(options, dbus.WithMatchObjectPath(r.Path))
+	}
+	if r.Interface != "" {
+		options = append(options, dbus.WithMatchInterface(r.Interface))
+	}
+	if r.Member != "" {
+		options = append(options, dbus.WithMatchMember(r.Member))
+	}
+	if r.Arg0Namespace != "" {
+		options = append(options, dbus.WithMatchOption("arg0namespace", r.Arg0Namespace))
+	}
+	return options
+}
+
+// Check if a received signal satisfies this rule.
+func (r MatchRule) matches(signal *dbus.Signal) bool {
+	iface, member := splitSignalName(signal.Name)
+	return (r.Sender == "" || r.Sender == signal.Sender) &&
+		(r.Path == "" || r.Path == signal.Path) &&
+		(r.Interface == "" || r.Interface == iface) &&
+		(r.Member == "" || r.Member == member) &&
+		(r.Arg0Namespace == "" || inNamespace(signal, r.Arg0Namespace))
+}
+
+// Check if the first argument of a signal is within a bus name namespace.
+func inNamespace(signal *dbus.Signal, namespace string) bool {
+	if len(signal.Body) == 0 {
+		return false
+	}
+	name, ok := signal.Body[0].(string)
+	return ok && (name == namespace || strings.HasPrefix(name, namespace+"."))
+}
+
+// Split a full signal name into its interface and member.
+func splitSignalName(name string) (iface, member string) {
+	i := strings.LastIndex(name, ".")
+	if i == -1 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// A stream of the signals which satisfy one match rule. Read them from C and
+// call Unsubscribe when finished. C is closed after Unsubscribe or once the
+// connection is closed.
+type Subscription struct {
+	C <-chan *dbus.Signal
+
+	rule MatchRule
+	ch   chan *dbus.Signal
+	done chan struct{}
+	wg   sync.WaitGroup
+	d    *dispatcher
+
+	mu    sync.Mutex
+	queue []*dbus.Signal // Signals waiting to be sent on ch, oldest first
+	wake  chan struct{}  // Nudges drain when the queue grows
+}
+
+// Get the rule this subscription was created with.
+func (s *Subscription) Rule() MatchRule {
+	return s.rule
+}
+
+// Stop receiving signals, close C, and remove the match rule from the bus.
+// It is safe to call more than once.
+func (s *Subscription) Unsubscribe() error {
+	return s.d.unsubscribe(s)
+}
+
+// Hand a signal to the subscriber without blocking the dispatcher. Signals
+// are queued and sent on in the order they arrived.
+func (s *Subscription) deliver(signal *dbus.Signal) {
+	s.mu.Lock()
+	s.queue = append(s.queue, signal)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Send queued signals on the channel, oldest first, until the subscription is
+// closed.
+func (s *Subscription) drain() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		signal := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- signal:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Stop delivery and close the channel once nothing else can write to it.
+func (s *Subscription) close() {
+	close(s.done)
+	s.wg.Wait()
+	close(s.ch)
+}
+
+// Routes signals arriving on a connection to only the subscriptions whose
+// match rule they satisfy. Every client and player on a connection shares one,
+// so no subscriber sees signals meant for another.
+type dispatcher struct {
+	conn *dbus.Conn
+	in   chan *dbus.Signal
+
+	mu     sync.Mutex
+	subs   map[*Subscription]bool
+	closed bool
+}
+
+// Create a dispatcher and start routing the signals of a connection. godbus
+// hands signals to a channel in the background once it is full, so order is
+// only kept while the dispatcher keeps up. Use sessionConn where order matters.
+func newDispatcher(conn *dbus.Conn) *dispatcher {
+	d := &dispatcher{
+		conn: conn,
+		in:   make(chan *dbus.Signal, 10),
+		subs: make(map[*Subscription]bool),
+	}
+	conn.Signal(d.in)
+	go d.run()
+	return d
+}
+
+// Open a private connection to the session bus whose signals are routed
+// straight to a dispatcher, in the order they arrive.
+func sessionConn() (*dbus.Conn, *dispatcher, error) {
+	d := &dispatcher{subs: make(map[*Subscription]bool)}
+	conn, err := dbus.SessionBusPrivate(dbus.WithSignalHandler(d))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	d.conn = conn
+	return conn, d, nil
+}
+
+// Route signals from the channel until the connection closes.
+func (d *dispatcher) run() {
+	for signal := range d.in {
+		d.DeliverSignal("", "", signal)
+	}
+	d.Terminate()
+}
+
+// Route a signal to the subscriptions whose rule it satisfies. Called by godbus
+// for connections opened by sessionConn.
+func (d *dispatcher) DeliverSignal(iface, name string, signal *dbus.Signal) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for sub := range d.subs {
+		if sub.rule.matches(signal) {
+			sub.deliver(signal)
+		}
+	}
+}
+
+// Close every subscription once the connection is closed.
+func (d *dispatcher) Terminate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for sub := range d.subs {
+		delete(d.subs, sub)
+		sub.close()
+	}
+}
+
+// Ask the bus for signals matching a rule and start delivering them.
+func (d *dispatcher) subscribe(rule MatchRule) (*Subscription, error) {
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+	if closed {
+		return nil, dbus.ErrClosed
+	}
+
+	err := d.conn.AddMatchSignal(rule.options()...)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *dbus.Signal, 10)
+	sub := &Subscription{
+		C:    ch,
+		rule: rule,
+		ch:   ch,
+		done: make(chan struct{}),
+		wake: make(chan struct{}, 1),
+		d:    d,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		close(sub.ch)
+		return nil, dbus.ErrClosed
+	}
+	d.subs[sub] = true
+	sub.wg.Add(1)
+	go sub.drain()
+	return sub, nil
+}
+
+// Stop delivering signals to a subscription and remove its rule from the bus.
+func (d *dispatcher) unsubscribe(sub *Subscription) error {
+	d.mu.Lock()
+	_, ok := d.subs[sub]
+	delete(d.subs, sub)
+	d.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	sub.close()
+	return d.conn.RemoveMatchSignal(sub.rule.options()...)
+}
+
+// Subscribe to a rule and pass each signal to the handler until the context is
+// done or the connection closes. The finish function is called once no more
+// signals will be handled.
+func (d *dispatcher) forward(ctx context.Context, rule MatchRule, handle func(*dbus.Signal), finish func()) error {
+	sub, err := d.subscribe(rule)
+	if err != nil {
+		finish()
+		return err
+	}
+	go func() {
+		defer finish()
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Unsubscribe()
+				return
+			case signal, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				handle(signal)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,499 @@
+package mpris
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const emitsChangedAnnotation = "org.freedesktop.DBus.Property.EmitsChangedSignal"
+
+// How a property may be accessed by clients, as in the introspection data.
+type Access string
+
+const (
+	AccessRead      Access = "read"
+	AccessWrite     Access = "write"
+	AccessReadWrite Access = "readwrite"
+)
+
+// Errors answered to clients that try to misuse a property.
+var (
+	errUnknownProperty = dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{"Unknown property"})
+	errUnknownIface    = dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{"Unknown interface"})
+	errReadOnly        = dbus.NewError("org.freedesktop.DBus.Error.PropertyReadOnly", []interface{}{"Property is read-only"})
+	errWriteOnly       = dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []interface{}{"Property is write-only"})
+)
+
+// One property held by a PropertyStore.
+type storedProperty struct {
+	value  dbus.Variant
+	access Access
+	emits  string // One of true, invalidates, const or false
+}
+
+// Holds the values of a server's properties and answers calls to
+// org.freedesktop.DBus.Properties for them. Changing a value with one of the
+// setters emits PropertiesChanged, so clients stay up to date without polling.
+type PropertyStore struct {
+	conn *dbus.Conn
+
+	mu     sync.RWMutex
+	props  map[string]map[string]*storedProperty
+	hooks  map[string]map[string]func(dbus.Variant) *dbus.Error
+	hidden map[string]bool // Interfaces which aren't exported, so clients can't see them
+}
+
+// Create a store for the properties described by an introspection node. Every
+// property starts with the value in defaults, or the zero value of its type.
+func NewPropertyStore(conn *dbus.Conn, node introspect.Node, defaults map[string]map[string]interface{}) *PropertyStore {
+	store := &PropertyStore{
+		conn:  conn,
+		props: make(map[string]map[string]*storedProperty),
+		hooks: make(map[string]map[string]func(dbus.Variant) *dbus.Error),
+	}
+	for _, iface := range node.Interfaces {
+		store.Declare(iface, defaults[iface.Name])
+	}
+	return store
+}
+
+// Add the properties of an interface to the store, keeping the values of any
+// that already exist.
+func (s *PropertyStore) Declare(iface introspect.Interface, defaults map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(iface.Properties) == 0 {
+		return
+	}
+	if s.props[iface.Name] == nil {
+		s.props[iface.Name] = make(map[string]*storedProperty)
+	}
+	for _, prop := range iface.Properties {
+		if _, ok := s.props[iface.Name][prop.Name]; ok {
+			continue
+		}
+		var variant dbus.Variant
+		if value, ok := defaults[prop.Name]; ok {
+			variant = dbus.MakeVariant(value)
+		} else {
+			sig, _ := dbus.ParseSignature(prop.Type)
+			variant = zeroVariant(sig)
+		}
+		s.props[iface.Name][prop.Name] = &storedProperty{
+			value:  variant,
+			access: Access(prop.Access),
+			emits:  emitsChanged(iface, prop),
+		}
+	}
+}
+
+// Hide the properties of these interfaces from clients, and stop announcing
+// changes to them, until the next call. Their values are kept.
+func (s *PropertyStore) hide(ifaces ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hidden = make(map[string]bool, len(ifaces))
+	for _, iface := range ifaces {
+		s.hidden[iface] = true
+	}
+}
+
+// Get the value of EmitsChangedSignal for a property. The property's own
+// annotation wins over the interface's, and the default is true.
+func emitsChanged(iface introspect.Interface, prop introspect.Property) string {
+	for _, annotation := range prop.Annotations {
+		if annotation.Name == emitsChangedAnnotation {
+			return annotation.Value
+		}
+	}
+	for _, annotation := range iface.Annotations {
+		if annotation.Name == emitsChangedAnnotation {
+			return annotation.Value
+		}
+	}
+	return "true"
+}
+
+// Build a variant holding the zero value for a signature.
+func zeroVariant(sig dbus.Signature) dbus.Variant {
+	switch sig.String() {
+	case "b":
+		return dbus.MakeVariant(false)
+	case "s":
+		return dbus.MakeVariant("")
+	case "o":
+		return dbus.MakeVariant(dbus.ObjectPath("/"))
+	case "d":
+		return dbus.MakeVariant(0.0)
+	case "x":
+		return dbus.MakeVariant(int64(0))
+	case "t":
+		return dbus.MakeVariant(uint64(0))
+	case "i":
+		return dbus.MakeVariant(int32(0))
+	case "u":
+		return dbus.MakeVariant(uint32(0))
+	case "as":
+		return dbus.MakeVariant([]string{})
+	case "ao":
+		return dbus.MakeVariant([]dbus.ObjectPath{})
+	case "a{sv}":
+		return dbus.MakeVariant(map[string]dbus.Variant{})
+	case "(b(oss))":
+		return dbus.MakeVariant(rawMaybePlaylist{Playlist: rawPlaylist{ID: "/"}})
+	}
+	return dbus.MakeVariant("")
+}
+
+//
+// Bus methods
+//
+
+// Answer a client's request for one property.
+func (s *PropertyStore) Get(iface, prop string) (dbus.Variant, *dbus.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	props, ok := s.props[iface]
+	if !ok || s.hidden[iface] {
+		return dbus.Variant{}, errUnknownIface
+	}
+	stored, ok := props[prop]
+	if !ok {
+		return dbus.Variant{}, errUnknownProperty
+	}
+	if stored.access == AccessWrite {
+		return dbus.Variant{}, errWriteOnly
+	}
+	return stored.value, nil
+}
+
+// Answer a client's request for all readable properties of an interface.
+func (s *PropertyStore) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]dbus.Variant)
+	if s.hidden[iface] {
+		return result, nil
+	}
+	for name, stored := range s.props[iface] {
+		if stored.access != AccessWrite {
+			result[name] = stored.value
+		}
+	}
+	return result, nil
+}
+
+// Answer a client's request to change a property. Only writable properties of
+// the right type are accepted, and the hook registered with OnSet may veto the
+// change.
+func (s *PropertyStore) Set(iface, prop string, value dbus.Variant) *dbus.Error {
+	s.mu.RLock()
+	props, ok := s.props[iface]
+	ok = ok && !s.hidden[iface]
+	var stored *storedProperty
+	if ok {
+		stored = props[prop]
+	}
+	hook := s.hooks[iface][prop]
+	s.mu.RUnlock()
+
+	if !ok {
+		return errUnknownIface
+	}
+	if stored == nil {
+		return errUnknownProperty
+	}
+	if stored.access == AccessRead {
+		return errReadOnly
+	}
+	if value.Signature() != stored.value.Signature() {
+		return &dbus.ErrMsgInvalidArg
+	}
+	if hook != nil {
+		if err := hook(value); err != nil {
+			return err
+		}
+	}
+	return DbusError(s.UpdateAll(iface, map[string]interface{}{prop: value}))
+}
+
+//
+// Server-side changes
+//
+
+// Register a function to call when a client sets a property. Returning an
+// error rejects the new value.
+func (s *PropertyStore) OnSet(iface, prop string, hook func(value dbus.Variant) *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hooks[iface] == nil {
+		s.hooks[iface] = make(map[string]func(dbus.Variant) *dbus.Error)
+	}
+	s.hooks[iface][prop] = hook
+}
+
+// Get the current value of a property.
+func (s *PropertyStore) Value(iface, prop string) (dbus.Variant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stored, ok := s.props[iface][prop]
+	if !ok {
+		return dbus.Variant{}, fmt.Errorf("%w: %s.%s", ErrNotSupported, iface, prop)
+	}
+	return stored.value, nil
+}
+
+// Change the value of one property and notify clients if it differs.
+func (s *PropertyStore) Update(iface, prop string, value interface{}) error {
+	return s.UpdateAll(iface, map[string]interface{}{prop: value})
+}
+
+// Change several properties of one interface at once and notify clients with
+// a single PropertiesChanged signal. Access is not checked, since the server
+// owns its properties, but the types must match.
+func (s *PropertyStore) UpdateAll(iface string, values map[string]interface{}) error {
+	changed := make(map[string]dbus.Variant)
+	var invalidated []string
+
+	s.mu.Lock()
+	props, ok := s.props[iface]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotSupported, iface)
+	}
+	for name, value := range values {
+		stored, ok := props[name]
+		if !ok {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %s.%s", ErrNotSupported, iface, name)
+		}
+		variant, ok := value.(dbus.Variant)
+		if !ok {
+			variant = dbus.MakeVariant(value)
+		}
+		if variant.Signature() != stored.value.Signature() {
+			s.mu.Unlock()
+			return wrongType(iface, name, value)
+		}
+	}
+	for name, value := range values {
+		stored := props[name]
+		variant, ok := value.(dbus.Variant)
+		if !ok {
+			variant = dbus.MakeVariant(value)
+		}
+		if reflect.DeepEqual(stored.value.Value(), variant.Value()) {
+			continue
+		}
+		stored.value = variant
+		switch stored.emits {
+		case "true":
+			changed[name] = variant
+		case "invalidates":
+			invalidated = append(invalidated, name)
+		}
+	}
+	hidden := s.hidden[iface]
+	s.mu.Unlock()
+
+	if hidden || len(changed) == 0 && len(invalidated) == 0 {
+		return nil
+	}
+	sort.Strings(invalidated)
+	return s.emit(iface, changed, invalidated)
+}
+
+//
+// Typed setters
+//
+
+// Properties on app: org.mpris.MediaPlayer2
+
+// Set the name of the player shown to users and notify clients.
+func (s *PropertyStore) SetIdentity(value string) error {
+	return s.Update(appInterface, "Identity", value)
+}
+
+// Set the basename of the player's .desktop file and notify clients.
+func (s *PropertyStore) SetDesktopEntry(value string) error {
+	return s.Update(appInterface, "DesktopEntry", value)
+}
+
+// Set the value of CanQuit and notify clients.
+func (s *PropertyStore) SetCanQuit(value bool) error {
+	return s.Update(appInterface, "CanQuit", value)
+}
+
+// Set the value of CanRaise and notify clients.
+func (s *PropertyStore) SetCanRaise(value bool) error {
+	return s.Update(appInterface, "CanRaise", value)
+}
+
+// Set the value of CanSetFullscreen and notify clients.
+func (s *PropertyStore) SetCanSetFullscreen(value bool) error {
+	return s.Update(appInterface, "CanSetFullscreen", value)
+}
+
+// Set the value of Fullscreen and notify clients.
+func (s *PropertyStore) SetFullscreen(value bool) error {
+	return s.Update(appInterface, "Fullscreen", value)
+}
+
+// Set the value of HasTrackList and notify clients.
+func (s *PropertyStore) SetHasTrackList(value bool) error {
+	return s.Update(appInterface, "HasTrackList", value)
+}
+
+// Set the value of SupportedUriSchemes and notify clients.
+func (s *PropertyStore) SetSupportedUriSchemes(value []string) error {
+	return s.Update(appInterface, "SupportedUriSchemes", value)
+}
+
+// Set the value of SupportedMimeTypes and notify clients.
+func (s *PropertyStore) SetSupportedMimeTypes(value []string) error {
+	return s.Update(appInterface, "SupportedMimeTypes", value)
+}
+
+// Properties on playback: org.mpris.MediaPlayer2.Player
+
+// Set the value of PlaybackStatus and notify clients.
+func (s *PropertyStore) SetPlaybackStatus(value PlaybackState) error {
+	return s.Update(playerInterface, "PlaybackStatus", string(value))
+}
+
+// Set the value of LoopStatus and notify clients.
+func (s *PropertyStore) SetLoopStatus(value LoopState) error {
+	return s.Update(playerInterface, "LoopStatus", string(value))
+}
+
+// Set the value of Rate and notify clients.
+func (s *PropertyStore) SetRate(value float64) error {
+	return s.Update(playerInterface, "Rate", value)
+}
+
+// Set the value of Shuffle and notify clients.
+func (s *PropertyStore) SetShuffle(value bool) error {
+	return s.Update(playerInterface, "Shuffle", value)
+}
+
+// Set the value of Volume and notify clients.
+func (s *PropertyStore) SetVolume(value float64) error {
+	return s.Update(playerInterface, "Volume", value)
+}
+
+// Set the current position in microseconds. Position never emits
+// PropertiesChanged, so call Server.Seeked instead when playback jumps.
+func (s *PropertyStore) SetPosition(position int64) error {
+	return s.Update(playerInterface, "Position", position)
+}
+
+// Set the value of MinimumRate and notify clients.
+func (s *PropertyStore) SetMinimumRate(value float64) error {
+	return s.Update(playerInterface, "MinimumRate", value)
+}
+
+// Set the value of MaximumRate and notify clients.
+func (s *PropertyStore) SetMaximumRate(value float64) error {
+	return s.Update(playerInterface, "MaximumRate", value)
+}
+
+// Set the metadata of the current track and notify clients.
+func (s *PropertyStore) SetMetadata(value Metadata) error {
+	return s.Update(playerInterface, "Metadata", value.Map())
+}
+
+// Set the value of CanGoNext and notify clients.
+func (s *PropertyStore) SetCanGoNext(value bool) error {
+	return s.Update(playerInterface, "CanGoNext", value)
+}
+
+// Set the value of CanGoPrevious and notify clients.
+func (s *PropertyStore) SetCanGoPrevious(value bool) error {
+	return s.Update(playerInterface, "CanGoPrevious", value)
+}
+
+// Set the value of CanPlay and notify clients.
+func (s *PropertyStore) SetCanPlay(value bool) error {
+	return s.Update(playerInterface, "CanPlay", value)
+}
+
+// Set the value of CanPause and notify clients.
+func (s *PropertyStore) SetCanPause(value bool) error {
+	return s.Update(playerInterface, "CanPause", value)
+}
+
+// Set the value of CanSeek and notify clients.
+func (s *PropertyStore) SetCanSeek(value bool) error {
+	return s.Update(playerInterface, "CanSeek", value)
+}
+
+// Set the value of CanControl and notify clients.
+func (s *PropertyStore) SetCanControl(value bool) error {
+	return s.Update(playerInterface, "CanControl", value)
+}
+
+// Properties on tracklist: org.mpris.MediaPlayer2.TrackList
+
+// Set the IDs of the tracks in the track list. Clients are told the list was
+// invalidated rather than sent it, so prefer Server.TrackListReplaced.
+func (s *PropertyStore) SetTracks(value []string) error {
+	return s.Update(trackListInterface, "Tracks", objectPaths(value))
+}
+
+// Set the value of CanEditTracks and notify clients.
+func (s *PropertyStore) SetCanEditTracks(value bool) error {
+	return s.Update(trackListInterface, "CanEditTracks", value)
+}
+
+// Properties on playlists: org.mpris.MediaPlayer2.Playlists
+
+// Set the value of PlaylistCount and notify clients.
+func (s *PropertyStore) SetPlaylistCount(value uint32) error {
+	return s.Update(playlistsInterface, "PlaylistCount", value)
+}
+
+// Set the orderings the player supports and notify clients.
+func (s *PropertyStore) SetOrderings(value []PlaylistOrdering) error {
+	orderings := make([]string, len(value))
+	for i, ordering := range value {
+		orderings[i] = string(ordering)
+	}
+	return s.Update(playlistsInterface, "Orderings", orderings)
+}
+
+// Set the playlist being played and notify clients. Pass nil if there is none.
+func (s *PropertyStore) SetActivePlaylist(value *Playlist) error {
+	active := rawMaybePlaylist{Playlist: rawPlaylist{ID: "/"}}
+	if value != nil {
+		active = rawMaybePlaylist{Valid: true, Playlist: value.raw()}
+	}
+	return s.Update(playlistsInterface, "ActivePlaylist", active)
+}
+
+// Send a PropertiesChanged signal for an interface.
+func (s *PropertyStore) emit(iface string, changed map[string]dbus.Variant, invalidated []string) error {
+	return emitPropertiesChanged(s.conn, iface, changed, invalidated)
+}
+
+// Send a PropertiesChanged signal from the MPRIS object on a connection.
+func emitPropertiesChanged(conn *dbus.Conn, iface string, changed map[string]dbus.Variant, invalidated []string) error {
+	if conn == nil {
+		return errors.New("No connection to emit signals on")
+	}
+	if changed == nil {
+		changed = map[string]dbus.Variant{}
+	}
+	if invalidated == nil {
+		invalidated = []string{}
+	}
+	return conn.Emit(objectPath, propertyInterface+"."+propertiesChangedMember, iface, changed, invalidated)
+}
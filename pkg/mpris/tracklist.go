@@ -0,0 +1,243 @@
+package mpris
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// Special track ID used to refer to the position before the first track.
+const NoTrack = "/org/mpris/MediaPlayer2/TrackList/NoTrack"
+
+//
+// Methods
+//
+
+// Methods on track list: org.mpris.MediaPlayer2.TrackList
+
+// Get the metadata for each of the given tracks. Tracks which are not known to
+// the player are omitted from the result.
+func (p *Player) GetTracksMetadata(trackIds []string) ([]Metadata, error) {
+	paths := make([]dbus.ObjectPath, len(trackIds))
+	for i, id := range trackIds {
+		paths[i] = dbus.ObjectPath(id)
+	}
+	var raw []map[string]dbus.Variant
+	err := p.call(trackListInterface+".GetTracksMetadata", paths).Store(&raw)
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]Metadata, len(raw))
+	for i, meta := range raw {
+		tracks[i] = ParseMetadata(meta)
+	}
+	return tracks, nil
+}
+
+// Add the media at the given URI to the track list after the given track. Use
+// NoTrack to insert at the beginning of the list. If setAsCurrent is true, the
+// new track will start playing.
+func (p *Player) AddTrack(uri, afterTrack string, setAsCurrent bool) error {
+	path := dbus.ObjectPath(afterTrack)
+	call := p.call(trackListInterface+".AddTrack", uri, path, setAsCurrent)
+	return call.Err
+}
+
+// Remove the given track from the track list.
+func (p *Player) RemoveTrack(trackId string) error {
+	path := dbus.ObjectPath(trackId)
+	call := p.call(trackListInterface+".RemoveTrack", path)
+	return call.Err
+}
+
+// Skip to the given track in the track list.
+func (p *Player) GoTo(trackId string) error {
+	path := dbus.ObjectPath(trackId)
+	call := p.call(trackListInterface+".GoTo", path)
+	return call.Err
+}
+
+//
+// Properties
+//
+
+// Properties on track list: org.mpris.MediaPlayer2.TrackList
+
+// Get the IDs of every track in the current track list, in order. Returns an
+// error if it can't be read.
+func (p *Player) GetTracks() ([]string, error) {
+	return getObjectPathList(p, trackListInterface, "Tracks")
+}
+
+// Get the IDs of every track in the current track list, in order.
+func (p *Player) Tracks() []string {
+	value, _ := p.GetTracks()
+	return value
+}
+
+// Get the value of CanEditTracks. Returns an error if it can't be read.
+func (p *Player) GetCanEditTracks() (bool, error) {
+	return getBool(p, trackListInterface, "CanEditTracks")
+}
+
+func (p *Player) CanEditTracks() bool {
+	value, _ := p.GetCanEditTracks()
+	return value
+}
+
+//
+// Signals
+//
+
+// The player's whole track list has been replaced.
+type TrackListReplacedEvent struct {
+	Player  *Player
+	Tracks  []string // The IDs of the new tracks, in order
+	Current string   // The ID of the current track
+}
+
+// A track has been added to the track list.
+type TrackAddedEvent struct {
+	Player   *Player
+	Metadata Metadata
+	After    string // The ID of the track it follows, or NoTrack for the start
+}
+
+// A track has been removed from the track list.
+type TrackRemovedEvent struct {
+	Player  *Player
+	TrackID string
+}
+
+// The metadata of a track in the track list has changed.
+type TrackMetadataChangedEvent struct {
+	Player   *Player
+	TrackID  string
+	Metadata Metadata
+}
+
+// Get a channel of events that the entire track list has been replaced. The
+// channel is closed when the player's context is done.
+func (p *Player) OnTrackListReplaced() (chan TrackListReplacedEvent, error) {
+	c := make(chan TrackListReplacedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(trackListInterface, "TrackListReplaced"), func(signal *dbus.Signal) {
+		if event, ok := parseTrackListReplaced(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
+}
+
+// Get a channel of events that a track has been added to the track list. The
+// channel is closed when the player's context is done.
+func (p *Player) OnTrackAdded() (chan TrackAddedEvent, error) {
+	c := make(chan TrackAddedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(trackListInterface, "TrackAdded"), func(signal *dbus.Signal) {
+		if event, ok := parseTrackAdded(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
+}
+
+// Get a channel of events that a track has been removed from the track list.
+// The channel is closed when the player's context is done.
+func (p *Player) OnTrackRemoved() (chan TrackRemovedEvent, error) {
+	c := make(chan TrackRemovedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(trackListInterface, "TrackRemoved"), func(signal *dbus.Signal) {
+		if event, ok := parseTrackRemoved(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
+}
+
+// Get a channel of events that the metadata of a track has changed. The
+// channel is closed when the player's context is done.
+func (p *Player) OnTrackMetadataChanged() (chan TrackMetadataChangedEvent, error) {
+	c := make(chan TrackMetadataChangedEvent, 10)
+	ctx := p.Context()
+	err := p.signals.forward(ctx, p.matchRule(trackListInterface, "TrackMetadataChanged"), func(signal *dbus.Signal) {
+		if event, ok := parseTrackMetadataChanged(p, signal); ok {
+			select {
+			case c <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(c) })
+	return c, err
+}
+
+// Decode a TrackListReplaced signal. Returns false if the body is malformed.
+func parseTrackListReplaced(player *Player, signal *dbus.Signal) (TrackListReplacedEvent, bool) {
+	if len(signal.Body) != 2 {
+		return TrackListReplacedEvent{}, false
+	}
+	paths, ok := signal.Body[0].([]dbus.ObjectPath)
+	if !ok {
+		return TrackListReplacedEvent{}, false
+	}
+	current, ok := signal.Body[1].(dbus.ObjectPath)
+	if !ok {
+		return TrackListReplacedEvent{}, false
+	}
+	tracks := make([]string, len(paths))
+	for i, path := range paths {
+		tracks[i] = string(path)
+	}
+	return TrackListReplacedEvent{Player: player, Tracks: tracks, Current: string(current)}, true
+}
+
+// Decode a TrackAdded signal. Returns false if the body is malformed.
+func parseTrackAdded(player *Player, signal *dbus.Signal) (TrackAddedEvent, bool) {
+	if len(signal.Body) != 2 {
+		return TrackAddedEvent{}, false
+	}
+	meta, ok := signal.Body[0].(map[string]dbus.Variant)
+	if !ok {
+		return TrackAddedEvent{}, false
+	}
+	after, ok := signal.Body[1].(dbus.ObjectPath)
+	if !ok {
+		return TrackAddedEvent{}, false
+	}
+	return TrackAddedEvent{Player: player, Metadata: ParseMetadata(meta), After: string(after)}, true
+}
+
+// Decode a TrackRemoved signal. Returns false if the body is malformed.
+func parseTrackRemoved(player *Player, signal *dbus.Signal) (TrackRemovedEvent, bool) {
+	if len(signal.Body) != 1 {
+		return TrackRemovedEvent{}, false
+	}
+	id, ok := signal.Body[0].(dbus.ObjectPath)
+	if !ok {
+		return TrackRemovedEvent{}, false
+	}
+	return TrackRemovedEvent{Player: player, TrackID: string(id)}, true
+}
+
+// Decode a TrackMetadataChanged signal. Returns false if the body is malformed.
+func parseTrackMetadataChanged(player *Player, signal *dbus.Signal) (TrackMetadataChangedEvent, bool) {
+	if len(signal.Body) != 2 {
+		return TrackMetadataChangedEvent{}, false
+	}
+	id, ok := signal.Body[0].(dbus.ObjectPath)
+	if !ok {
+		return TrackMetadataChangedEvent{}, false
+	}
+	meta, ok := signal.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return TrackMetadataChangedEvent{}, false
+	}
+	return TrackMetadataChangedEvent{Player: player, TrackID: string(id), Metadata: ParseMetadata(meta)}, true
+}
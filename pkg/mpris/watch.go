@@ -0,0 +1,87 @@
+package mpris
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName              = "org.freedesktop.DBus"
+	nameOwnerChangedName = busName + ".NameOwnerChanged"
+)
+
+// Kind of change to the set of running players, implemented as a type to act
+// like an enum.
+type PlayerEventType string
+
+const (
+	PlayerAdded   PlayerEventType = "Added"
+	PlayerRemoved PlayerEventType = "Removed"
+	OwnerChanged  PlayerEventType = "OwnerChanged"
+)
+
+// A player has appeared on the bus, exited, or its name has been taken over
+// by a different connection.
+type PlayerEvent struct {
+	Type     PlayerEventType
+	Name     string  // The bus name of the player
+	OldOwner string  // The previous unique name, empty for PlayerAdded
+	NewOwner string  // The new unique name, empty for PlayerRemoved
+	Player   *Player // A handle to the player, nil for PlayerRemoved
+}
+
+// Matches the bus announcing that a player's name has a new owner.
+var playerOwnersRule = MatchRule{
+	Sender:        busName,
+	Interface:     busName,
+	Member:        "NameOwnerChanged",
+	Arg0Namespace: appInterface,
+}
+
+// Get a channel of events as players start and quit. The channel is closed
+// when the client's context is done.
+func (c *Client) WatchPlayers() (chan PlayerEvent, error) {
+	events := make(chan PlayerEvent, 10)
+	ctx := c.Context()
+	err := c.signals.forward(ctx, playerOwnersRule, func(signal *dbus.Signal) {
+		if event, ok := c.parseNameOwnerChanged(signal); ok {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		}
+	}, func() { close(events) })
+	return events, err
+}
+
+// Build a player event from a NameOwnerChanged signal. Returns false if the
+// signal is not about an MPRIS player.
+func (c *Client) parseNameOwnerChanged(signal *dbus.Signal) (PlayerEvent, bool) {
+	if signal.Name != nameOwnerChangedName || len(signal.Body) != 3 {
+		return PlayerEvent{}, false
+	}
+	name, _ := signal.Body[0].(string)
+	oldOwner, _ := signal.Body[1].(string)
+	newOwner, _ := signal.Body[2].(string)
+	if !strings.HasPrefix(name, appInterface+".") {
+		return PlayerEvent{}, false
+	}
+
+	event := PlayerEvent{Name: name, OldOwner: oldOwner, NewOwner: newOwner}
+	switch {
+	case oldOwner == "" && newOwner != "":
+		event.Type = PlayerAdded
+	case oldOwner != "" && newOwner == "":
+		event.Type = PlayerRemoved
+	case oldOwner != "" && newOwner != "":
+		event.Type = OwnerChanged
+	default:
+		return PlayerEvent{}, false
+	}
+	if newOwner != "" {
+		player := c.newPlayer(name, newOwner)
+		event.Player = &player
+	}
+	return event, true
+}